@@ -0,0 +1,196 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// Roles a user can have
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// adminCount returns the number of administrators. Callers must hold mu.
+func adminCount() int {
+	n := 0
+	for _, user := range users {
+		if user.Role == RoleAdmin {
+			n++
+		}
+	}
+	return n
+}
+
+// bootstrapAdmin makes the user with secretCode the first administrator,
+// creating the user if needed. It does nothing once an admin exists.
+//
+// There are two ways to create the first admin: ADMIN_SECRET, applied by
+// bootstrapAdmin at startup, and ADMIN_BOOTSTRAP_TOKEN, which lets
+// registerAdminHandler create one over HTTP. Both only work while there is
+// no admin, so whichever is used first wins and the other then does
+// nothing. ADMIN_SECRET runs before the server accepts requests, so when it
+// is set the bootstrap token is never used.
+func bootstrapAdmin(secretCode string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if adminCount() > 0 {
+		return nil
+	}
+	if len(secretCode) < minSecretCodeLength {
+		return fmt.Errorf("ADMIN_SECRET must be at least %d characters", minSecretCodeLength)
+	}
+
+	original, existed := users[secretCode]
+	admin := original
+	if !existed {
+		admin = User{
+			ID:         nextUserID(),
+			SecretCode: secretCode,
+			Name:       "Administrator",
+		}
+	}
+	admin.Role = RoleAdmin
+	putUser(admin)
+
+	if err := saveState(); err != nil {
+		if existed {
+			putUser(original)
+		} else {
+			deleteUser(admin)
+		}
+		return err
+	}
+	return nil
+}
+
+// registerAdminHandler creates an administrator. While there is no admin,
+// the bootstrap token from ADMIN_BOOTSTRAP_TOKEN is accepted instead of an
+// admin's credentials (see bootstrapAdmin); after that only an existing
+// admin can create more.
+func registerAdminHandler(w http.ResponseWriter, r *http.Request) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var request struct {
+		BootstrapToken string `json:"bootstrapToken"`
+		SecretCode     string `json:"secretCode"`
+		Name           string `json:"name"`
+		Email          string `json:"email"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	newAdmin := User{
+		SecretCode: request.SecretCode,
+		Name:       request.Name,
+		Email:      request.Email,
+		Role:       RoleAdmin,
+	}
+
+	var createdBy string
+	if adminCount() == 0 {
+		bootstrapToken := os.Getenv("ADMIN_BOOTSTRAP_TOKEN")
+		if bootstrapToken == "" || subtle.ConstantTimeCompare([]byte(request.BootstrapToken), []byte(bootstrapToken)) != 1 {
+			writeError(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	} else {
+		admin, ok := authorizeAdmin(w, r, legacySecretCode(r))
+		if !ok {
+			return
+		}
+		createdBy = admin.ID
+	}
+
+	if errs := validate(newAdmin); len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	if _, exists := users[newAdmin.SecretCode]; exists {
+		writeError(w, "Secret code already in use", http.StatusBadRequest)
+		return
+	}
+
+	newAdmin.ID = nextUserID()
+
+	putUser(newAdmin)
+
+	if createdBy != "" {
+		recordAudit(createdBy, "register_admin", "", "created admin "+newAdmin.ID)
+	}
+
+	if err := saveState(); err != nil {
+		deleteUser(newAdmin)
+		if createdBy != "" {
+			dropLastAudit()
+		}
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(viewUser(newAdmin))
+}
+
+// updateUserRoleHandler promotes or demotes the user in the request path.
+// The last admin cannot be demoted, so there is always someone left to
+// manage roles.
+func updateUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var request struct {
+		Role string `json:"role"`
+	}
+
+	if err := decodeStrict(r, &request); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	admin, ok := authorizeAdmin(w, r, legacySecretCode(r))
+	if !ok {
+		return
+	}
+
+	if request.Role != RoleUser && request.Role != RoleAdmin {
+		writeError(w, "Invalid role: must be "+RoleUser+" or "+RoleAdmin, http.StatusBadRequest)
+		return
+	}
+
+	target, exists := findUserByID(r.PathValue("id"))
+	if !exists {
+		writeError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if target.Role != request.Role {
+		if target.Role == RoleAdmin && adminCount() == 1 {
+			writeError(w, "Cannot demote the last admin", http.StatusConflict)
+			return
+		}
+
+		original := target
+		target.Role = request.Role
+		putUser(target)
+
+		recordAudit(admin.ID, "role_change", "", fmt.Sprintf("user %s from %s to %s", target.ID, original.Role, target.Role))
+
+		if err := saveState(); err != nil {
+			putUser(original)
+			dropLastAudit()
+			writeError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	json.NewEncoder(w).Encode(viewUser(target))
+}
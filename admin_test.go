@@ -0,0 +1,324 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestRegisterAdmin(t *testing.T) {
+	resetState(t)
+	t.Setenv("ADMIN_BOOTSTRAP_TOKEN", "bootstrap-token")
+	registerUser(t, "user-secret")
+
+	newAdmin := func(secretCode string) map[string]string {
+		return map[string]string{"secretCode": secretCode, "name": "Admin", "email": "admin@example.com"}
+	}
+	with := func(body map[string]string, key, value string) map[string]string {
+		body[key] = value
+		return body
+	}
+
+	bootstrapTests := []struct {
+		name string
+		body map[string]string
+		want int
+	}{
+		{"no bootstrap token", newAdmin("first-admin"), http.StatusUnauthorized},
+		{"wrong bootstrap token", with(newAdmin("first-admin"), "bootstrapToken", "guess"), http.StatusUnauthorized},
+		{"bootstrap token", with(newAdmin("first-admin"), "bootstrapToken", "bootstrap-token"), http.StatusOK},
+		{"bootstrap token once an admin exists", with(newAdmin("second-admin"), "bootstrapToken", "bootstrap-token"), http.StatusUnauthorized},
+	}
+
+	for _, tt := range bootstrapTests {
+		w := request(t, http.MethodPost, "/registerAdmin", "", tt.body)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+	}
+
+	adminToken := login(t, "first-admin")
+	userToken := login(t, "user-secret")
+
+	tests := []struct {
+		name  string
+		token string
+		body  map[string]string
+		want  int
+	}{
+		{"no token", "", newAdmin("second-admin"), http.StatusUnauthorized},
+		{"unknown token", "not-a-token", newAdmin("second-admin"), http.StatusUnauthorized},
+		{"admin secret code instead of a token", "", with(newAdmin("second-admin"), "adminSecretCode", "first-admin"), http.StatusUnauthorized},
+		{"regular user", userToken, newAdmin("second-admin"), http.StatusForbidden},
+		{"existing admin", adminToken, newAdmin("second-admin"), http.StatusOK},
+		{"duplicate admin secret", adminToken, newAdmin("second-admin"), http.StatusBadRequest},
+		{"secret of a user", adminToken, newAdmin("user-secret"), http.StatusBadRequest},
+		{"no secret code", adminToken, newAdmin(""), http.StatusUnprocessableEntity},
+		{"invalid admin", adminToken, newAdmin("short"), http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		w := request(t, http.MethodPost, "/registerAdmin", tt.token, tt.body)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, secretCode := range []string{"first-admin", "second-admin"} {
+		if role := users[secretCode].Role; role != RoleAdmin {
+			t.Errorf("%s has role %q, want %q", secretCode, role, RoleAdmin)
+		}
+	}
+	if role := users["user-secret"].Role; role != RoleUser {
+		t.Errorf("user-secret has role %q, want %q", role, RoleUser)
+	}
+}
+
+func TestRegisterAdminWithoutBootstrapToken(t *testing.T) {
+	resetState(t)
+	t.Setenv("ADMIN_BOOTSTRAP_TOKEN", "")
+
+	// With no token configured, an empty one in the body must not match.
+	w := request(t, http.MethodPost, "/registerAdmin", "", map[string]string{
+		"bootstrapToken": "",
+		"secretCode":     "first-admin",
+		"name":           "Admin",
+		"email":          "admin@example.com",
+	})
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestRegisteredAdminsUseAdminEndpoints(t *testing.T) {
+	resetState(t)
+	_, adminToken := addAdmin(t, "admin-secret")
+	registerUser(t, "user-secret")
+	id := submitComplaint(t, login(t, "user-secret"), "Noise", 2)
+
+	// The hardcoded secret from before admin accounts is unknown on the
+	// legacy aliases. A user's secret code is known, but grants no admin
+	// access.
+	tests := []struct {
+		secretCode string
+		want       int
+	}{
+		{"admin", http.StatusUnauthorized},
+		{"user-secret", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if w := request(t, http.MethodGet, "/getAllComplaintsForAdmin?secretCode="+tt.secretCode, "", nil); w.Code != tt.want {
+			t.Errorf("listing with %q: status %d, want %d", tt.secretCode, w.Code, tt.want)
+		}
+		if w := request(t, http.MethodPost, "/resolveComplaint", "", map[string]string{"id": id, "secretCode": tt.secretCode}); w.Code != tt.want {
+			t.Errorf("resolving with %q: status %d, want %d", tt.secretCode, w.Code, tt.want)
+		}
+	}
+
+	w := request(t, http.MethodGet, "/admin/complaints", adminToken, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("listing: status %d: %s", w.Code, w.Body)
+	}
+	var page complaintPage
+	decode(t, w, &page)
+	if len(page.Complaints) != 1 || page.Complaints[0].ID != id {
+		t.Errorf("listing = %+v, want complaint %s", page.Complaints, id)
+	}
+
+	if w := request(t, http.MethodPatch, "/complaints/"+id+"/resolve", adminToken, nil); w.Code != http.StatusNoContent {
+		t.Errorf("resolving: status %d, want %d: %s", w.Code, http.StatusNoContent, w.Body)
+	}
+	mu.Lock()
+	resolved := complaints[id].Status == StatusResolved
+	mu.Unlock()
+	if !resolved {
+		t.Error("complaint was not resolved")
+	}
+}
+func TestBootstrapAdmin(t *testing.T) {
+	resetState(t)
+
+	if err := bootstrapAdmin("short"); err == nil {
+		t.Error("bootstrapAdmin accepted a short secret code")
+	}
+
+	if err := bootstrapAdmin("admin-secret"); err != nil {
+		t.Fatalf("bootstrapAdmin: %v", err)
+	}
+	mu.Lock()
+	admin := users["admin-secret"]
+	mu.Unlock()
+	if admin.ID == "" || admin.Role != RoleAdmin {
+		t.Fatalf("bootstrapped admin = %+v", admin)
+	}
+
+	// Once there is an admin, bootstrapping again changes nothing.
+	registerUser(t, "user-secret")
+	if err := bootstrapAdmin("user-secret"); err != nil {
+		t.Fatalf("bootstrapAdmin: %v", err)
+	}
+	mu.Lock()
+	role := users["user-secret"].Role
+	mu.Unlock()
+	if role != RoleUser {
+		t.Errorf("second bootstrap made user-secret %q", role)
+	}
+
+	var s Store
+	if err := s.Load(dataFile); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if s.Users["admin-secret"].Role != RoleAdmin {
+		t.Errorf("saved admin = %+v", s.Users["admin-secret"])
+	}
+
+	// ADMIN_SECRET was used first, so the bootstrap token no longer works.
+	t.Setenv("ADMIN_BOOTSTRAP_TOKEN", "bootstrap-token")
+	w := request(t, http.MethodPost, "/registerAdmin", "", map[string]string{
+		"bootstrapToken": "bootstrap-token",
+		"secretCode":     "other-admin",
+		"name":           "Admin",
+		"email":          "admin@example.com",
+	})
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("bootstrap token after ADMIN_SECRET: status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestBootstrapAdminPromotesAnExistingUser(t *testing.T) {
+	resetState(t)
+	id := registerUser(t, "user-secret")
+
+	if err := bootstrapAdmin("user-secret"); err != nil {
+		t.Fatalf("bootstrapAdmin: %v", err)
+	}
+	mu.Lock()
+	user := users["user-secret"]
+	n := len(users)
+	mu.Unlock()
+	if user.ID != id || user.Role != RoleAdmin || user.Name != "User user-secret" {
+		t.Errorf("promoted user = %+v", user)
+	}
+	if n != 1 {
+		t.Errorf("%d users, want 1", n)
+	}
+}
+
+func TestUpdateUserRole(t *testing.T) {
+	resetState(t)
+	adminID, adminToken := addAdmin(t, "admin-secret")
+	userID := registerUser(t, "user-secret")
+	userToken := login(t, "user-secret")
+
+	setRole := func(token, id, role string) int {
+		t.Helper()
+		return request(t, http.MethodPatch, "/admin/users/"+id+"/role", token, map[string]string{"role": role}).Code
+	}
+
+	if got := setRole(adminToken, adminID, RoleUser); got != http.StatusConflict {
+		t.Errorf("demoting the last admin: status %d, want %d", got, http.StatusConflict)
+	}
+	if got := setRole(adminToken, userID, "owner"); got != http.StatusBadRequest {
+		t.Errorf("unknown role: status %d, want %d", got, http.StatusBadRequest)
+	}
+	if got := setRole(adminToken, "999", RoleAdmin); got != http.StatusNotFound {
+		t.Errorf("unknown user: status %d, want %d", got, http.StatusNotFound)
+	}
+	if got := setRole(userToken, userID, RoleAdmin); got != http.StatusForbidden {
+		t.Errorf("user promoting themselves: status %d, want %d", got, http.StatusForbidden)
+	}
+
+	w := request(t, http.MethodPatch, "/admin/users/"+userID+"/role", adminToken, map[string]string{"role": RoleAdmin})
+	if w.Code != http.StatusOK {
+		t.Fatalf("promoting: status %d: %s", w.Code, w.Body)
+	}
+	var promoted userView
+	decode(t, w, &promoted)
+	if promoted.ID != userID || promoted.Role != RoleAdmin {
+		t.Errorf("promoted user = %+v", promoted)
+	}
+
+	// The existing token now carries admin rights.
+	if w := request(t, http.MethodGet, "/admin/complaints", userToken, nil); w.Code != http.StatusOK {
+		t.Errorf("promoted user listing: status %d, want %d", w.Code, http.StatusOK)
+	}
+
+	// With two admins either may step down, but not both.
+	if got := setRole(adminToken, adminID, RoleUser); got != http.StatusOK {
+		t.Errorf("demoting one of two admins: status %d, want %d", got, http.StatusOK)
+	}
+	if got := setRole(userToken, userID, RoleUser); got != http.StatusConflict {
+		t.Errorf("demoting the new last admin: status %d, want %d", got, http.StatusConflict)
+	}
+	if w := request(t, http.MethodGet, "/admin/complaints", adminToken, nil); w.Code != http.StatusForbidden {
+		t.Errorf("demoted admin listing: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var changes []string
+	for _, entry := range auditLog {
+		if entry.Action == "role_change" {
+			changes = append(changes, entry.AdminID+" "+entry.Detail)
+		}
+	}
+	want := []string{
+		adminID + " user " + userID + " from user to admin",
+		adminID + " user " + adminID + " from admin to user",
+	}
+	if !slices.Equal(changes, want) {
+		t.Errorf("role changes %q, want %q", changes, want)
+	}
+}
+
+func TestAdminEndpointsEnforceRole(t *testing.T) {
+	resetState(t)
+	config.AuthRequestBurst = 1000
+
+	_, adminToken := addAdmin(t, "admin-secret")
+	userID := registerUser(t, "user-secret")
+	userToken := login(t, "user-secret")
+	registerUser(t, "other-secret")
+	otherToken := login(t, "other-secret")
+	// Submitting also gives the user a rate limiter for the admin to reset.
+	id := submitComplaint(t, userToken, "Noise", 2)
+
+	endpoints := []struct {
+		method, target string
+		body           interface{}
+		want           int
+	}{
+		{http.MethodGet, "/admin/complaints", nil, http.StatusOK},
+		{http.MethodGet, "/getAllComplaintsForAdmin", nil, http.StatusOK},
+		{http.MethodGet, "/admin/complaints/byResolutionNote?q=fixed", nil, http.StatusOK},
+		{http.MethodGet, "/admin/complaints/export", nil, http.StatusOK},
+		{http.MethodPost, "/admin/complaints/import", map[string]interface{}{"userSecretCode": "user-secret", "complaints": []interface{}{}}, http.StatusOK},
+		{http.MethodGet, "/auditLog", nil, http.StatusOK},
+		{http.MethodGet, "/admin/ratelimits", nil, http.StatusOK},
+		{http.MethodDelete, "/admin/ratelimits/" + userID, nil, http.StatusNoContent},
+		{http.MethodPatch, "/admin/users/" + userID + "/role", map[string]string{"role": RoleUser}, http.StatusOK},
+		{http.MethodPost, "/registerAdmin", map[string]string{"secretCode": "second-admin", "name": "Second", "email": "second@example.com"}, http.StatusOK},
+		{http.MethodPatch, "/complaints/" + id + "/status", map[string]string{"status": "in_progress"}, http.StatusOK},
+		{http.MethodPatch, "/complaints/" + id + "/resolve", nil, http.StatusNoContent},
+	}
+
+	for _, e := range endpoints {
+		callers := []struct {
+			name, token string
+			want        int
+		}{
+			{"no credentials", "", http.StatusUnauthorized},
+			{"unknown token", "not-a-token", http.StatusUnauthorized},
+			{"owner", userToken, http.StatusForbidden},
+			{"other user", otherToken, http.StatusForbidden},
+			{"admin", adminToken, e.want},
+		}
+		for _, c := range callers {
+			if w := request(t, e.method, e.target, c.token, e.body); w.Code != c.want {
+				t.Errorf("%s %s as %s: status %d, want %d: %s", e.method, e.target, c.name, w.Code, c.want, w.Body)
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// AuditEntry records one action taken by an administrator
+type AuditEntry struct {
+	Timestamp   time.Time `json:"timestamp"`
+	AdminID     string    `json:"adminId"`
+	Action      string    `json:"action"`
+	ComplaintID string    `json:"complaintId,omitempty"`
+	Detail      string    `json:"detail,omitempty"`
+}
+
+// auditLog holds every admin action in the order it happened. It is guarded
+// by mu.
+var auditLog []AuditEntry
+
+// recordAudit appends an entry to the audit log. Callers must hold mu.
+func recordAudit(adminID, action, complaintID, detail string) {
+	auditLog = append(auditLog, AuditEntry{
+		Timestamp:   time.Now(),
+		AdminID:     adminID,
+		Action:      action,
+		ComplaintID: complaintID,
+		Detail:      detail,
+	})
+}
+
+// dropLastAudit removes the entry added by the last recordAudit, for a
+// change that could not be saved. Callers must hold mu.
+func dropLastAudit() {
+	auditLog = auditLog[:len(auditLog)-1]
+}
+
+// auditLogHandler returns the audit log, optionally limited to entries at or
+// after since and to a single complaint_id.
+func auditLogHandler(w http.ResponseWriter, r *http.Request) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := authorizeAdmin(w, r, legacySecretCode(r)); !ok {
+		return
+	}
+
+	var since time.Time
+	if value := r.URL.Query().Get("since"); value != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeError(w, fmt.Sprintf("invalid since %q: must be an RFC 3339 time", value), http.StatusBadRequest)
+			return
+		}
+	}
+	complaintID := r.URL.Query().Get("complaint_id")
+
+	entries := []AuditEntry{}
+	for _, entry := range auditLog {
+		if entry.Timestamp.Before(since) {
+			continue
+		}
+		if complaintID != "" && entry.ComplaintID != complaintID {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	json.NewEncoder(w).Encode(entries)
+}
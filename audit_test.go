@@ -0,0 +1,132 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestResolveIsAudited(t *testing.T) {
+	resetState(t)
+	adminID, adminToken := addAdmin(t, "admin-secret")
+	registerUser(t, "owner-secret")
+	ownerToken := login(t, "owner-secret")
+	id := submitComplaint(t, ownerToken, "Noise", 2)
+
+	before := time.Now()
+	w := request(t, http.MethodPatch, "/complaints/"+id+"/resolve", adminToken, map[string]string{"resolutionNote": "Fixed"})
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("resolving: status %d: %s", w.Code, w.Body)
+	}
+	after := time.Now()
+
+	var entries []AuditEntry
+	decode(t, request(t, http.MethodGet, "/auditLog", adminToken, nil), &entries)
+	if len(entries) != 1 {
+		t.Fatalf("audit log has %d entries, want 1: %+v", len(entries), entries)
+	}
+	entry := entries[0]
+	if entry.AdminID != adminID || entry.Action != "resolve" || entry.ComplaintID != id || entry.Detail != "open to resolved: Fixed" {
+		t.Errorf("audit entry = %+v", entry)
+	}
+	if entry.Timestamp.Before(before) || entry.Timestamp.After(after) {
+		t.Errorf("audit entry timestamp %v is outside %v to %v", entry.Timestamp, before, after)
+	}
+
+	var s Store
+	if err := s.Load(dataFile); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(s.AuditLog) != 1 || s.AuditLog[0].Action != "resolve" {
+		t.Errorf("saved audit log = %+v, want the resolve entry", s.AuditLog)
+	}
+}
+
+func TestAuditLogRejectsNonAdmins(t *testing.T) {
+	resetState(t)
+	registerUser(t, "user-secret")
+	userToken := login(t, "user-secret")
+	_, adminToken := addAdmin(t, "admin-secret")
+
+	tests := []struct {
+		name, query, token string
+		want               int
+	}{
+		{"no credentials", "", "", http.StatusUnauthorized},
+		{"unknown token", "", "not-a-token", http.StatusUnauthorized},
+		{"user token", "", userToken, http.StatusForbidden},
+		{"admin secret code", "?secretCode=admin-secret", "", http.StatusUnauthorized},
+		{"admin token", "", adminToken, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		if w := request(t, http.MethodGet, "/auditLog"+tt.query, tt.token, nil); w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
+
+func TestAuditLogFilters(t *testing.T) {
+	resetState(t)
+	_, adminToken := addAdmin(t, "admin-secret")
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mu.Lock()
+	auditLog = []AuditEntry{
+		{Timestamp: start, AdminID: "1", Action: "resolve", ComplaintID: "1"},
+		{Timestamp: start.Add(time.Hour), AdminID: "1", Action: "delete", ComplaintID: "2"},
+		{Timestamp: start.Add(2 * time.Hour), AdminID: "1", Action: "status_change", ComplaintID: "1"},
+	}
+	mu.Unlock()
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"resolve", "delete", "status_change"}},
+		{"since=" + url.QueryEscape(start.Add(time.Hour).Format(time.RFC3339)), []string{"delete", "status_change"}},
+		{"complaint_id=1", []string{"resolve", "status_change"}},
+		{"complaint_id=1&since=" + url.QueryEscape(start.Add(time.Minute).Format(time.RFC3339)), []string{"status_change"}},
+		{"complaint_id=3", nil},
+	}
+
+	for _, tt := range tests {
+		var entries []AuditEntry
+		decode(t, request(t, http.MethodGet, "/auditLog?"+tt.query, adminToken, nil), &entries)
+		var actions []string
+		for _, entry := range entries {
+			actions = append(actions, entry.Action)
+		}
+		if !slices.Equal(actions, tt.want) {
+			t.Errorf("?%s: actions %v, want %v", tt.query, actions, tt.want)
+		}
+	}
+
+	if w := request(t, http.MethodGet, "/auditLog?since=yesterday", adminToken, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid since: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestOnlyAdminDeletionsAreAudited(t *testing.T) {
+	resetState(t)
+	adminID, adminToken := addAdmin(t, "admin-secret")
+	registerUser(t, "owner-secret")
+	ownerToken := login(t, "owner-secret")
+	withdrawn := submitComplaint(t, ownerToken, "Withdrawn", 2)
+	deleted := submitComplaint(t, ownerToken, "Deleted", 2)
+
+	if w := request(t, http.MethodDelete, "/complaints/"+withdrawn, ownerToken, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("withdrawing: status %d: %s", w.Code, w.Body)
+	}
+	if w := request(t, http.MethodDelete, "/complaints/"+deleted, adminToken, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("deleting as admin: status %d: %s", w.Code, w.Body)
+	}
+
+	var entries []AuditEntry
+	decode(t, request(t, http.MethodGet, "/auditLog", adminToken, nil), &entries)
+	if len(entries) != 1 || entries[0].AdminID != adminID || entries[0].Action != "delete" || entries[0].ComplaintID != deleted {
+		t.Errorf("audit log = %+v, want only the admin's deletion of %s", entries, deleted)
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sessionTTL is how long a token issued by loginHandler stays valid.
+const sessionTTL = time.Hour
+
+// session is the server-side state behind a bearer token.
+type session struct {
+	userID    string
+	expiresAt time.Time
+}
+
+// sessionsMu guards sessions. It is separate from mu so that token checks
+// never wait on handlers.
+var sessionsMu sync.Mutex
+
+// sessions maps bearer tokens to their session.
+var sessions = make(map[string]session)
+
+var (
+	errMissingToken = errors.New("missing bearer token")
+	errInvalidToken = errors.New("invalid token")
+	errExpiredToken = errors.New("token expired")
+)
+
+type contextKey int
+
+const (
+	userIDKey contextKey = iota
+	secretCodeKey
+)
+
+// newSession stores s under a fresh random token and returns the token.
+func newSession(s session) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
+	now := time.Now()
+	for t, existing := range sessions {
+		if !now.Before(existing.expiresAt) {
+			delete(sessions, t)
+		}
+	}
+	sessions[token] = s
+
+	return token, nil
+}
+
+// lookupSession returns the session for the bearer token on r.
+func lookupSession(r *http.Request) (session, error) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return session{}, errMissingToken
+	}
+	token := strings.TrimPrefix(auth, "Bearer ")
+
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
+	s, exists := sessions[token]
+	if !exists {
+		return session{}, errInvalidToken
+	}
+	if !time.Now().Before(s.expiresAt) {
+		delete(sessions, token)
+		return session{}, errExpiredToken
+	}
+	return s, nil
+}
+
+// parseAndVerify validates the bearer token on r and returns the user ID it
+// was issued for.
+func parseAndVerify(r *http.Request) (string, error) {
+	s, err := lookupSession(r)
+	if err != nil {
+		return "", err
+	}
+	if s.userID == "" {
+		return "", errInvalidToken
+	}
+	return s.userID, nil
+}
+
+// requireAuth rejects requests without a valid user bearer token. The
+// authenticated user ID is available to next through authUserID.
+func requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := parseAndVerify(r)
+		if err != nil {
+			writeError(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userIDKey, userID)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// requireAuthOrSecretCode is requireAuth for the legacy endpoints: when no
+// bearer token is sent, the user is identified by their legacySecretCode.
+func requireAuthOrSecretCode(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			requireAuth(next)(w, r)
+			return
+		}
+
+		secretCode := legacySecretCode(r)
+
+		mu.Lock()
+		user, exists := users[secretCode]
+		mu.Unlock()
+
+		if secretCode == "" || !exists {
+			writeError(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userIDKey, user.ID)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// authUserID returns the user ID stored on the request by requireAuth.
+func authUserID(r *http.Request) string {
+	userID, _ := r.Context().Value(userIDKey).(string)
+	return userID
+}
+
+// legacySecretCode returns the secret code legacyRoute read from a request
+// to one of the deprecated endpoints, which accept it instead of a bearer
+// token. It is empty on every other route, so a secret code sent to them
+// is never used as a credential.
+func legacySecretCode(r *http.Request) string {
+	secretCode, _ := r.Context().Value(secretCodeKey).(string)
+	return secretCode
+}
+
+// requestAdmin returns the user making r, identified like requestUser, if
+// they are an administrator. Callers must hold mu.
+func requestAdmin(r *http.Request, secretCode string) (User, bool) {
+	user, exists := requestUser(r, secretCode)
+	if !exists || user.Role != RoleAdmin {
+		return User{}, false
+	}
+	return user, true
+}
+
+// authorizeAdmin is requestAdmin for admin-only endpoints. It answers 401
+// when r carries no known credential and 403 when it comes from a user who
+// is not an admin. Callers must hold mu.
+func authorizeAdmin(w http.ResponseWriter, r *http.Request, secretCode string) (User, bool) {
+	user, exists := requestUser(r, secretCode)
+	if !exists {
+		writeError(w, "Unauthorized", http.StatusUnauthorized)
+		return User{}, false
+	}
+	if user.Role != RoleAdmin {
+		writeError(w, "Forbidden", http.StatusForbidden)
+		return User{}, false
+	}
+	return user, true
+}
+
+// requestUser identifies the user making r from its bearer token or, when
+// there is none, from secretCode. Callers must hold mu.
+func requestUser(r *http.Request, secretCode string) (User, bool) {
+	if r.Header.Get("Authorization") != "" {
+		userID, err := parseAndVerify(r)
+		if err != nil {
+			return User{}, false
+		}
+		return findUserByID(userID)
+	}
+
+	if secretCode == "" {
+		return User{}, false
+	}
+	user, exists := users[secretCode]
+	return user, exists
+}
+
+// findUserByID looks up a user by ID. Callers must hold mu.
+func findUserByID(id string) (User, bool) {
+	secretCode, exists := secretCodesByID[id]
+	if !exists {
+		return User{}, false
+	}
+	user, exists := users[secretCode]
+	return user, exists
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoginTokenSubmitFlow(t *testing.T) {
+	resetState(t)
+	registerUser(t, "user-secret")
+
+	token := login(t, "user-secret")
+	id := submitComplaint(t, token, "Noise", 2)
+
+	w := request(t, http.MethodGet, "/complaints/"+id, token, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("viewing with the token: status %d: %s", w.Code, w.Body)
+	}
+
+	for name, token := range map[string]string{"no token": "", "unknown token": "not-a-token"} {
+		if w := request(t, http.MethodGet, "/complaints/"+id, token, nil); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status %d, want %d", name, w.Code, http.StatusUnauthorized)
+		}
+	}
+
+	if w := request(t, http.MethodPost, "/login", "", map[string]string{"secretCode": "wrong-secret"}); w.Code != http.StatusNotFound {
+		t.Errorf("login with an unknown secret: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestExpiredTokenIsRejected(t *testing.T) {
+	resetState(t)
+	registerUser(t, "user-secret")
+	token := login(t, "user-secret")
+
+	sessionsMu.Lock()
+	s := sessions[token]
+	s.expiresAt = time.Now().Add(-time.Second)
+	sessions[token] = s
+	sessionsMu.Unlock()
+
+	w := request(t, http.MethodGet, "/complaints", token, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if !strings.Contains(w.Body.String(), errExpiredToken.Error()) {
+		t.Errorf("body %q does not mention the expiry", w.Body)
+	}
+
+	sessionsMu.Lock()
+	_, exists := sessions[token]
+	sessionsMu.Unlock()
+	if exists {
+		t.Error("expired session was not removed")
+	}
+}
+
+func TestAdminTokens(t *testing.T) {
+	resetState(t)
+	_, adminToken := addAdmin(t, "admin-secret")
+	registerUser(t, "user-secret")
+	userToken := login(t, "user-secret")
+	id := submitComplaint(t, userToken, "Noise", 2)
+
+	// Admins are users too, but a user token does not grant admin access.
+	if w := request(t, http.MethodGet, "/complaints", adminToken, nil); w.Code != http.StatusOK {
+		t.Errorf("admin token on a user route: status %d: %s", w.Code, w.Body)
+	}
+	if w := request(t, http.MethodGet, "/admin/complaints", userToken, nil); w.Code != http.StatusForbidden {
+		t.Errorf("user token on an admin route: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	if w := request(t, http.MethodGet, "/admin/complaints", adminToken, nil); w.Code != http.StatusOK {
+		t.Errorf("listing with the admin token: status %d: %s", w.Code, w.Body)
+	}
+	if w := request(t, http.MethodPatch, "/complaints/"+id+"/resolve", adminToken, nil); w.Code != http.StatusNoContent {
+		t.Errorf("resolving with the admin token: status %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestSecretCodesNeverInResponses(t *testing.T) {
+	resetState(t)
+	t.Setenv("ADMIN_BOOTSTRAP_TOKEN", "bootstrap-token")
+
+	const userSecret, adminSecret, newAdminSecret = "user-code-4711", "admin-code-4712", "admin-code-4713"
+
+	responses := []string{}
+	send := func(method, target, token string, body interface{}) {
+		t.Helper()
+		w := request(t, method, target, token, body)
+		if w.Code >= 400 {
+			t.Fatalf("%s %s: status %d: %s", method, target, w.Code, w.Body)
+		}
+		responses = append(responses, method+" "+target+": "+w.Body.String())
+	}
+
+	send(http.MethodPost, "/registerAdmin", "", map[string]string{"bootstrapToken": "bootstrap-token", "secretCode": adminSecret, "name": "Admin", "email": "admin@example.com"})
+	send(http.MethodPost, "/users", "", map[string]string{"secretCode": userSecret, "name": "User", "email": "user@example.com"})
+	userToken := login(t, userSecret)
+	adminToken := login(t, adminSecret)
+	send(http.MethodPost, "/login", "", map[string]string{"secretCode": userSecret})
+	send(http.MethodPost, "/login", "", map[string]string{"secretCode": adminSecret})
+	id := submitComplaint(t, userToken, "Noise", 2)
+	send(http.MethodGet, "/complaints", userToken, nil)
+	send(http.MethodGet, "/complaints/"+id, userToken, nil)
+	send(http.MethodGet, "/admin/complaints", adminToken, nil)
+	send(http.MethodPost, "/registerAdmin", adminToken, map[string]string{"secretCode": newAdminSecret, "name": "New", "email": "new@example.com"})
+	send(http.MethodPatch, "/admin/users/2/role", adminToken, map[string]string{"role": RoleAdmin})
+
+	for _, response := range responses {
+		for _, secret := range []string{userSecret, adminSecret, newAdminSecret} {
+			if strings.Contains(response, secret) {
+				t.Errorf("secret code %q in response to %s", secret, response)
+			}
+		}
+	}
+}
+
+func TestViewComplaintOfAnotherUser(t *testing.T) {
+	resetState(t)
+	registerUser(t, "owner-secret")
+	registerUser(t, "other-secret")
+	id := submitComplaint(t, login(t, "owner-secret"), "Noise", 2)
+
+	w := request(t, http.MethodGet, "/complaints/"+id, login(t, "other-secret"), nil)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestFindUserByID(t *testing.T) {
+	resetState(t)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	setUsers(map[string]User{
+		"secret-one": {ID: "1", SecretCode: "secret-one", Name: "One"},
+		"secret-two": {ID: "2", SecretCode: "secret-two", Name: "Two"},
+	})
+	if user, exists := findUserByID("2"); !exists || user.Name != "Two" {
+		t.Errorf("findUserByID(2) = %+v, %v, want user Two", user, exists)
+	}
+	if _, exists := findUserByID("3"); exists {
+		t.Error("findUserByID(3) found a user")
+	}
+
+	putUser(User{ID: "3", SecretCode: "secret-three", Name: "Three"})
+	putUser(User{ID: "1", SecretCode: "secret-one", Name: "Renamed"})
+	if user, exists := findUserByID("3"); !exists || user.Name != "Three" {
+		t.Errorf("findUserByID(3) = %+v, %v, want the added user", user, exists)
+	}
+	if user, _ := findUserByID("1"); user.Name != "Renamed" {
+		t.Errorf("findUserByID(1) = %+v, want the replaced user", user)
+	}
+
+	deleteUser(User{ID: "3", SecretCode: "secret-three"})
+	if _, exists := findUserByID("3"); exists {
+		t.Error("findUserByID(3) found a deleted user")
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Config holds the tunable limits of the server
+type Config struct {
+	ComplaintsPerUserPerHour int
+	AuthRequestsPerSecond    float64
+	AuthRequestBurst         int
+	// TrustedProxies are the proxies whose X-Forwarded-For header is
+	// believed when identifying the client.
+	TrustedProxies []*net.IPNet
+}
+
+var config = Config{
+	ComplaintsPerUserPerHour: 10,
+	AuthRequestsPerSecond:    5,
+	AuthRequestBurst:         10,
+}
+
+// loadConfig overrides the defaults in config with values from the
+// environment.
+func loadConfig() error {
+	if value := os.Getenv("COMPLAINTS_PER_USER_PER_HOUR"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid COMPLAINTS_PER_USER_PER_HOUR %q: must be a positive integer", value)
+		}
+		config.ComplaintsPerUserPerHour = n
+	}
+	if value := os.Getenv("AUTH_REQUESTS_PER_SECOND"); value != "" {
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid AUTH_REQUESTS_PER_SECOND %q: must be a positive number", value)
+		}
+		config.AuthRequestsPerSecond = n
+	}
+	if value := os.Getenv("AUTH_REQUEST_BURST"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid AUTH_REQUEST_BURST %q: must be a positive integer", value)
+		}
+		config.AuthRequestBurst = n
+	}
+	if value := os.Getenv("TRUSTED_PROXIES"); value != "" {
+		proxies, err := parseNetworks(value)
+		if err != nil {
+			return fmt.Errorf("invalid TRUSTED_PROXIES %q: %v", value, err)
+		}
+		config.TrustedProxies = proxies
+	}
+	return nil
+}
+
+// parseNetworks parses a comma-separated list of IP addresses and CIDR
+// ranges. A bare address is a network of just that address.
+func parseNetworks(value string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is not an IP address", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exportComplaintsHandler writes every complaint as CSV or, with
+// format=json, as a JSON array.
+func exportComplaintsHandler(w http.ResponseWriter, r *http.Request) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := authorizeAdmin(w, r, legacySecretCode(r)); !ok {
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		writeError(w, fmt.Sprintf("invalid format %q: must be csv or json", format), http.StatusBadRequest)
+		return
+	}
+
+	all := make([]Complaint, 0, len(complaints))
+	for _, complaint := range complaints {
+		all = append(all, complaint)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return lessID(all[i].ID, all[j].ID)
+	})
+
+	if format == "json" {
+		json.NewEncoder(w).Encode(all)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="complaints.csv"`)
+
+	// The status line has already been sent, so a failed write can only be
+	// reported by aborting the response rather than ending it cleanly.
+	if err := writeComplaintsCSV(w, all); err != nil {
+		log.Printf("exporting complaints: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// writeComplaintsCSV writes a header row followed by one row per complaint.
+func writeComplaintsCSV(w io.Writer, all []Complaint) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"ID", "UserID", "Title", "Summary", "Severity", "Resolved"}); err != nil {
+		return err
+	}
+	for _, complaint := range all {
+		err := writer.Write([]string{
+			complaint.ID,
+			complaint.UserID,
+			csvText(complaint.Title),
+			csvText(complaint.Summary),
+			strconv.Itoa(complaint.Severity),
+			strconv.FormatBool(complaint.Status == StatusResolved),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvText prefixes user-supplied text that a spreadsheet would run as a
+// formula with a single quote, so it is shown as text instead.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// importError reports why one item of a bulk import was rejected.
+type importError struct {
+	Index  int              `json:"index"`
+	Error  string           `json:"error"`
+	Fields validationErrors `json:"fields,omitempty"`
+}
+
+// importComplaintsHandler creates complaints for an existing user from a
+// JSON array. Each item is validated on its own, so bad items are reported
+// without failing the rest of the batch.
+func importComplaintsHandler(w http.ResponseWriter, r *http.Request) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var request struct {
+		UserSecretCode string            `json:"userSecretCode"`
+		Complaints     []json.RawMessage `json:"complaints"`
+	}
+
+	if err := decodeStrict(r, &request); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	admin, ok := authorizeAdmin(w, r, legacySecretCode(r))
+	if !ok {
+		return
+	}
+
+	user, exists := users[request.UserSecretCode]
+	if !exists {
+		writeError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	report := struct {
+		Created []string      `json:"created"`
+		Errors  []importError `json:"errors"`
+	}{[]string{}, []importError{}}
+
+	for i, raw := range request.Complaints {
+		var item struct {
+			Title    string          `json:"title"`
+			Summary  string          `json:"summary"`
+			Severity int             `json:"severity"`
+			Status   ComplaintStatus `json:"status"`
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&item); err != nil {
+			report.Errors = append(report.Errors, importError{Index: i, Error: err.Error()})
+			continue
+		}
+
+		if item.Status == "" {
+			item.Status = StatusOpen
+		}
+
+		newComplaint := Complaint{
+			Title:    item.Title,
+			Summary:  item.Summary,
+			Severity: item.Severity,
+			UserID:   user.ID,
+		}
+
+		errs := validate(newComplaint)
+		if !item.Status.valid() {
+			errs["status"] = "is not a valid status"
+		}
+		if len(errs) > 0 {
+			report.Errors = append(report.Errors, importError{Index: i, Error: "Validation failed", Fields: errs})
+			continue
+		}
+
+		now := time.Now()
+		newComplaint.ID = nextComplaintID()
+		newComplaint.Status = StatusOpen
+		newComplaint.CreatedAt = now
+		newComplaint.UpdatedAt = now
+		if item.Status != StatusOpen {
+			newComplaint.setStatus(item.Status, "admin:"+admin.ID, now)
+		}
+
+		complaints[newComplaint.ID] = newComplaint
+		report.Created = append(report.Created, newComplaint.ID)
+	}
+
+	if len(report.Created) > 0 {
+		recordAudit(admin.ID, "import", "", fmt.Sprintf("imported %d complaints for user %s", len(report.Created), user.ID))
+
+		if err := saveState(); err != nil {
+			for _, id := range report.Created {
+				delete(complaints, id)
+			}
+			dropLastAudit()
+			writeError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	json.NewEncoder(w).Encode(report)
+}
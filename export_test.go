@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExportCSV(t *testing.T) {
+	resetState(t)
+	_, adminToken := addAdmin(t, "admin-secret")
+
+	mu.Lock()
+	complaints["1"] = Complaint{ID: "1", UserID: "7", Title: `Noise, "again"`, Summary: "Line one\nLine two, with a comma", Severity: 2, Status: StatusOpen}
+	complaints["2"] = Complaint{ID: "2", UserID: "8", Title: "=HYPERLINK(\"http://example.com\")", Summary: "-1+2", Severity: 5, Status: StatusResolved}
+	complaints["10"] = Complaint{ID: "10", UserID: "7", Title: "@SUM(A1)", Summary: "+cmd", Severity: 1, Status: StatusInProgress}
+	mu.Unlock()
+
+	w := request(t, http.MethodGet, "/admin/complaints/export?format=csv", adminToken, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/csv" {
+		t.Errorf("Content-Type %q, want text/csv", got)
+	}
+	if got := w.Header().Get("Content-Disposition"); !strings.Contains(got, `filename="complaints.csv"`) {
+		t.Errorf("Content-Disposition %q has no filename", got)
+	}
+
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("reading CSV: %v", err)
+	}
+	want := [][]string{
+		{"ID", "UserID", "Title", "Summary", "Severity", "Resolved"},
+		{"1", "7", `Noise, "again"`, "Line one\nLine two, with a comma", "2", "false"},
+		{"2", "8", "'=HYPERLINK(\"http://example.com\")", "'-1+2", "5", "true"},
+		{"10", "7", "'@SUM(A1)", "'+cmd", "1", "false"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("CSV rows = %q, want %q", rows, want)
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	resetState(t)
+	_, adminToken := addAdmin(t, "admin-secret")
+	registerUser(t, "owner-secret")
+	ownerToken := login(t, "owner-secret")
+	submitComplaint(t, ownerToken, "First", 1)
+	submitComplaint(t, ownerToken, "Second", 2)
+
+	var exported []Complaint
+	decode(t, request(t, http.MethodGet, "/admin/complaints/export?format=json", adminToken, nil), &exported)
+	if got := ids(exported); !reflect.DeepEqual(got, []string{"1", "2"}) {
+		t.Errorf("exported %v, want [1 2]", got)
+	}
+
+	if w := request(t, http.MethodGet, "/admin/complaints/export?format=xml", adminToken, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("format=xml: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := request(t, http.MethodGet, "/admin/complaints/export", ownerToken, nil); w.Code != http.StatusForbidden {
+		t.Errorf("exporting as a user: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestCSVText(t *testing.T) {
+	tests := map[string]string{
+		"":           "",
+		"Noise":      "Noise",
+		"=1+1":       "'=1+1",
+		"+1":         "'+1",
+		"-1":         "'-1",
+		"@SUM(A1)":   "'@SUM(A1)",
+		"\tTabbed":   "'\tTabbed",
+		"\rReturned": "'\rReturned",
+		"a=b":        "a=b",
+		"'quoted":    "'quoted",
+	}
+
+	for in, want := range tests {
+		if got := csvText(in); got != want {
+			t.Errorf("csvText(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestWriteComplaintsCSVReportsWriteErrors(t *testing.T) {
+	err := writeComplaintsCSV(failingWriter{}, []Complaint{{ID: "1", Title: "Noise"}})
+	if err == nil || err.Error() != "disk full" {
+		t.Errorf("writeComplaintsCSV = %v, want the write error", err)
+	}
+}
+
+func TestImportReportsEachItem(t *testing.T) {
+	resetState(t)
+	adminID, adminToken := addAdmin(t, "admin-secret")
+	ownerID := registerUser(t, "owner-secret")
+
+	items := []interface{}{
+		map[string]interface{}{"title": "Good", "summary": "Fine", "severity": 2},
+		map[string]interface{}{"title": "", "severity": 9},
+		map[string]interface{}{"title": "Extra", "severity": 1, "colour": "red"},
+		map[string]interface{}{"title": "Bad status", "severity": 1, "status": "closed"},
+		map[string]interface{}{"title": "Already fixed", "severity": 3, "status": "resolved"},
+		"not an object",
+	}
+	w := request(t, http.MethodPost, "/admin/complaints/import", adminToken, map[string]interface{}{
+		"userSecretCode": "owner-secret",
+		"complaints":     items,
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	var report struct {
+		Created []string      `json:"created"`
+		Errors  []importError `json:"errors"`
+	}
+	decode(t, w, &report)
+
+	if !reflect.DeepEqual(report.Created, []string{"1", "2"}) {
+		t.Errorf("created %v, want [1 2]", report.Created)
+	}
+	var indexes []int
+	for _, e := range report.Errors {
+		indexes = append(indexes, e.Index)
+	}
+	if !reflect.DeepEqual(indexes, []int{1, 2, 3, 5}) {
+		t.Fatalf("errors at %v, want [1 2 3 5]: %+v", indexes, report.Errors)
+	}
+	wantFields := validationErrors{"title": "is required", "severity": "must be between 1 and 5"}
+	if !reflect.DeepEqual(report.Errors[0].Fields, wantFields) {
+		t.Errorf("item 1 fields = %v, want %v", report.Errors[0].Fields, wantFields)
+	}
+	if !strings.Contains(report.Errors[1].Error, "colour") {
+		t.Errorf("item 2 error = %q, want it to name the unknown field", report.Errors[1].Error)
+	}
+	if got := report.Errors[2].Fields["status"]; got == "" {
+		t.Errorf("item 3 fields = %v, want a status error", report.Errors[2].Fields)
+	}
+
+	mu.Lock()
+	good, fixed := complaints["1"], complaints["2"]
+	entries := append([]AuditEntry(nil), auditLog...)
+	mu.Unlock()
+	if good.UserID != ownerID || good.Status != StatusOpen || good.CreatedAt.IsZero() {
+		t.Errorf("imported complaint = %+v", good)
+	}
+	if fixed.Status != StatusResolved || fixed.ResolvedAt == nil || fixed.StatusChangedBy != "admin:"+adminID {
+		t.Errorf("imported resolved complaint = %+v", fixed)
+	}
+	if len(entries) != 1 || entries[0].Action != "import" {
+		t.Errorf("audit log = %+v, want one import entry", entries)
+	}
+
+	var s Store
+	if err := s.Load(dataFile); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(s.Complaints) != 2 {
+		t.Errorf("saved %d complaints, want 2", len(s.Complaints))
+	}
+}
+
+func TestImportForUnknownUser(t *testing.T) {
+	resetState(t)
+	_, adminToken := addAdmin(t, "admin-secret")
+
+	w := request(t, http.MethodPost, "/admin/complaints/import", adminToken, map[string]interface{}{
+		"userSecretCode": "nobody-here",
+		"complaints":     []interface{}{map[string]interface{}{"title": "Good", "severity": 2}},
+	})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	mu.Lock()
+	n := len(complaints)
+	mu.Unlock()
+	if n != 0 {
+		t.Errorf("%d complaints created, want 0", n)
+	}
+}
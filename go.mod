@@ -1 +1,3 @@
-module awesomeProct
\ No newline at end of file
+module awesomeProct
+
+go 1.22
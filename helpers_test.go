@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Keep the per-request log lines out of the test output.
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// resetState gives the test empty server state and a data file of its own,
+// and restores the configuration when the test ends.
+func resetState(t *testing.T) {
+	t.Helper()
+
+	mu.Lock()
+	setUsers(make(map[string]User))
+	complaints = make(map[string]Complaint)
+	auditLog = []AuditEntry{}
+	lastUserID.Store(0)
+	lastComplaintID.Store(0)
+	dataFile = filepath.Join(t.TempDir(), "data.json")
+	mu.Unlock()
+
+	sessionsMu.Lock()
+	sessions = make(map[string]session)
+	sessionsMu.Unlock()
+
+	// The tables are shared with rateLimitTables, so they are emptied in
+	// place rather than replaced.
+	rateLimitMu.Lock()
+	for _, limiters := range rateLimitTables {
+		clear(limiters)
+	}
+	rateLimitMu.Unlock()
+
+	saved := config
+	t.Cleanup(func() { config = saved })
+}
+
+// request sends a request through the router. A non-nil body is sent as
+// JSON, and a non-empty token as a bearer token.
+func request(t *testing.T, method, target, token string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("encoding request body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	r := httptest.NewRequest(method, target, reader)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	NewRouter().ServeHTTP(w, r)
+	return w
+}
+
+// decode decodes the JSON response body of w into v.
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+
+	if err := json.NewDecoder(w.Body).Decode(v); err != nil {
+		t.Fatalf("decoding response %q: %v", w.Body.String(), err)
+	}
+}
+
+// registerUser registers a user with secretCode and returns their ID.
+func registerUser(t *testing.T, secretCode string) string {
+	t.Helper()
+
+	w := request(t, http.MethodPost, "/users", "", map[string]string{
+		"secretCode": secretCode,
+		"name":       "User " + secretCode,
+		"email":      secretCode + "@example.com",
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("registering %s: status %d: %s", secretCode, w.Code, w.Body)
+	}
+
+	var user User
+	decode(t, w, &user)
+	return user.ID
+}
+
+// login returns a bearer token for the user with secretCode.
+func login(t *testing.T, secretCode string) string {
+	t.Helper()
+
+	w := request(t, http.MethodPost, "/login", "", map[string]string{"secretCode": secretCode})
+	if w.Code != http.StatusOK {
+		t.Fatalf("logging in %s: status %d: %s", secretCode, w.Code, w.Body)
+	}
+
+	var response struct {
+		Token string `json:"token"`
+	}
+	decode(t, w, &response)
+	return response.Token
+}
+
+// submitComplaint submits a complaint as the user with token and returns
+// its ID.
+func submitComplaint(t *testing.T, token, title string, severity int) string {
+	t.Helper()
+
+	w := request(t, http.MethodPost, "/complaints", token, map[string]interface{}{
+		"title":    title,
+		"summary":  "Summary of " + title,
+		"severity": severity,
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("submitting %q: status %d: %s", title, w.Code, w.Body)
+	}
+
+	// IDs are issued in order, so the new complaint has the latest one.
+	return strconv.FormatInt(lastComplaintID.Load(), 10)
+}
+
+// addAdmin adds an administrator with secretCode and returns their ID and
+// a bearer token for them.
+func addAdmin(t *testing.T, secretCode string) (string, string) {
+	t.Helper()
+
+	mu.Lock()
+	admin := User{
+		ID:         nextUserID(),
+		SecretCode: secretCode,
+		Name:       "Admin " + secretCode,
+		Email:      secretCode + "@example.com",
+		Role:       RoleAdmin,
+	}
+	putUser(admin)
+	mu.Unlock()
+
+	return admin.ID, login(t, secretCode)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// complaintListOptions controls filtering, sorting and pagination of a
+// complaint listing.
+type complaintListOptions struct {
+	Page        int
+	PageSize    int
+	Resolved    *bool
+	Status      ComplaintStatus
+	Severity    *int
+	MinSeverity int
+	Sort        string
+}
+
+// Sort orders accepted by the sort query parameter. Complaint IDs are issued
+// in increasing order, so submission order is ID order.
+const (
+	sortSubmittedAsc  = "submitted_asc"
+	sortSubmittedDesc = "submitted_desc"
+	sortSeverityDesc  = "severity_desc"
+	sortResolvedDesc  = "resolved_desc"
+)
+
+// sortAliases maps every accepted sort value to its canonical order.
+var sortAliases = map[string]string{
+	"id":              sortSubmittedAsc,
+	sortSubmittedAsc:  sortSubmittedAsc,
+	sortSubmittedDesc: sortSubmittedDesc,
+	"severity":        sortSeverityDesc,
+	sortSeverityDesc:  sortSeverityDesc,
+	sortResolvedDesc:  sortResolvedDesc,
+}
+
+// complaintPage is one page of a complaint listing. TotalCount counts the
+// complaints that pass the filters and is what TotalPages is based on.
+// Total counts every complaint in the listing before filtering, so clients
+// can show "N of M".
+type complaintPage struct {
+	Complaints []Complaint `json:"complaints"`
+	Page       int         `json:"page"`
+	PageSize   int         `json:"page_size"`
+	TotalPages int         `json:"total_pages"`
+	TotalCount int         `json:"total_count"`
+	Total      int         `json:"total"`
+}
+
+// parseComplaintListOptions reads the listing options from query. Page sizes
+// above maxPageSize are capped, and pages outside the listing are left for
+// paginate to return empty.
+func parseComplaintListOptions(query url.Values) (complaintListOptions, error) {
+	opts := complaintListOptions{
+		Page:     1,
+		PageSize: defaultPageSize,
+		Sort:     sortSubmittedAsc,
+	}
+
+	if value := query.Get("page"); value != "" {
+		page, err := strconv.Atoi(value)
+		if err != nil {
+			return opts, fmt.Errorf("invalid page %q: must be an integer", value)
+		}
+		opts.Page = page
+	}
+
+	// page_size is the preferred name; pageSize is kept for existing
+	// clients.
+	for _, name := range []string{"pageSize", "page_size"} {
+		if value := query.Get(name); value != "" {
+			pageSize, err := strconv.Atoi(value)
+			if err != nil || pageSize < 1 {
+				return opts, fmt.Errorf("invalid %s %q: must be a positive integer", name, value)
+			}
+			if pageSize > maxPageSize {
+				pageSize = maxPageSize
+			}
+			opts.PageSize = pageSize
+		}
+	}
+
+	if value := query.Get("resolved"); value != "" {
+		resolved, err := strconv.ParseBool(value)
+		if err != nil {
+			return opts, fmt.Errorf("invalid resolved %q: must be true or false", value)
+		}
+		opts.Resolved = &resolved
+	}
+
+	if value := query.Get("status"); value != "" {
+		status := ComplaintStatus(value)
+		if !status.valid() {
+			return opts, fmt.Errorf("invalid status %q: must be %s, %s, %s or %s", value, StatusOpen, StatusInProgress, StatusResolved, StatusRejected)
+		}
+		opts.Status = status
+	}
+
+	if value := query.Get("severity"); value != "" {
+		severity, err := strconv.Atoi(value)
+		if err != nil {
+			return opts, fmt.Errorf("invalid severity %q: must be an integer", value)
+		}
+		opts.Severity = &severity
+	}
+
+	// severity_gte is the preferred name; minSeverity is kept for
+	// existing clients.
+	for _, name := range []string{"minSeverity", "severity_gte"} {
+		if value := query.Get(name); value != "" {
+			minSeverity, err := strconv.Atoi(value)
+			if err != nil {
+				return opts, fmt.Errorf("invalid %s %q: must be an integer", name, value)
+			}
+			opts.MinSeverity = minSeverity
+		}
+	}
+
+	if value := query.Get("sort"); value != "" {
+		sortOrder, ok := sortAliases[value]
+		if !ok {
+			return opts, fmt.Errorf("invalid sort %q: must be %s, %s, %s or %s", value, sortSubmittedAsc, sortSubmittedDesc, sortSeverityDesc, sortResolvedDesc)
+		}
+		opts.Sort = sortOrder
+	}
+
+	return opts, nil
+}
+
+// listComplaints filters, sorts and paginates all according to opts.
+func listComplaints(all []Complaint, opts complaintListOptions) (complaintPage, error) {
+	matched := []Complaint{}
+	for _, complaint := range all {
+		if opts.Resolved != nil && (complaint.Status == StatusResolved) != *opts.Resolved {
+			continue
+		}
+		if opts.Status != "" && complaint.Status != opts.Status {
+			continue
+		}
+		if opts.Severity != nil && complaint.Severity != *opts.Severity {
+			continue
+		}
+		if complaint.Severity < opts.MinSeverity {
+			continue
+		}
+		matched = append(matched, complaint)
+	}
+
+	// Sort by ID first so that ties in any other order are stable.
+	sort.Slice(matched, func(i, j int) bool {
+		return lessID(matched[i].ID, matched[j].ID)
+	})
+	switch opts.Sort {
+	case sortSubmittedDesc:
+		sort.SliceStable(matched, func(i, j int) bool {
+			return lessID(matched[j].ID, matched[i].ID)
+		})
+	case sortSeverityDesc:
+		sort.SliceStable(matched, func(i, j int) bool {
+			return matched[i].Severity > matched[j].Severity
+		})
+	case sortResolvedDesc:
+		// Unresolved complaints sort last
+		sort.SliceStable(matched, func(i, j int) bool {
+			a, b := matched[i].ResolvedAt, matched[j].ResolvedAt
+			return a != nil && (b == nil || a.After(*b))
+		})
+	}
+
+	pageComplaints, err := paginate(matched, opts.Page, opts.PageSize)
+	if err != nil {
+		return complaintPage{}, err
+	}
+
+	return complaintPage{
+		Complaints: pageComplaints,
+		Page:       opts.Page,
+		PageSize:   opts.PageSize,
+		TotalPages: totalPages(len(matched), opts.PageSize),
+		TotalCount: len(matched),
+		Total:      len(all),
+	}, nil
+}
+
+// lessID orders numeric IDs numerically and anything else lexically.
+func lessID(a, b string) bool {
+	x, errA := strconv.ParseInt(a, 10, 64)
+	y, errB := strconv.ParseInt(b, 10, 64)
+	if errA != nil || errB != nil {
+		return a < b
+	}
+	return x < y
+}
@@ -0,0 +1,464 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// seedComplaints returns n complaints with IDs 1 to n in a shuffled order.
+// Complaint i has severity i%5+1. Every third one is resolved and of the
+// rest, those with an even ID are in progress.
+func seedComplaints(n int) []Complaint {
+	all := make([]Complaint, n)
+	for i := 1; i <= n; i++ {
+		status := StatusOpen
+		switch {
+		case i%3 == 0:
+			status = StatusResolved
+		case i%2 == 0:
+			status = StatusInProgress
+		}
+		all[i-1] = Complaint{
+			ID:       strconv.Itoa(i),
+			Title:    "Complaint " + strconv.Itoa(i),
+			Severity: i%5 + 1,
+			Status:   status,
+		}
+	}
+	rand.New(rand.NewSource(1)).Shuffle(n, func(i, j int) {
+		all[i], all[j] = all[j], all[i]
+	})
+	return all
+}
+
+func ids(complaints []Complaint) []string {
+	out := make([]string, len(complaints))
+	for i, c := range complaints {
+		out[i] = c.ID
+	}
+	return out
+}
+
+func mustListOptions(t *testing.T, query string) complaintListOptions {
+	t.Helper()
+
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := parseComplaintListOptions(values)
+	if err != nil {
+		t.Fatalf("parseComplaintListOptions(%q): %v", query, err)
+	}
+	return opts
+}
+
+func TestListComplaintsFilters(t *testing.T) {
+	all := seedComplaints(36)
+
+	tests := []struct {
+		query string
+		check func(Complaint) bool
+		count int
+	}{
+		{"", func(Complaint) bool { return true }, 36},
+		{"resolved=true", func(c Complaint) bool { return c.Status == StatusResolved }, 12},
+		{"resolved=false", func(c Complaint) bool { return c.Status != StatusResolved }, 24},
+		{"status=resolved", func(c Complaint) bool { return c.Status == StatusResolved }, 12},
+		{"status=in_progress", func(c Complaint) bool { return c.Status == StatusInProgress }, 12},
+		{"status=open", func(c Complaint) bool { return c.Status == StatusOpen }, 12},
+		{"status=rejected", func(c Complaint) bool { return false }, 0},
+		{"minSeverity=4", func(c Complaint) bool { return c.Severity >= 4 }, 14},
+		{"resolved=true&minSeverity=4", func(c Complaint) bool { return c.Status == StatusResolved && c.Severity >= 4 }, 5},
+		{"status=in_progress&minSeverity=4", func(c Complaint) bool { return c.Status == StatusInProgress && c.Severity >= 4 }, 5},
+	}
+
+	for _, tt := range tests {
+		page, err := listComplaints(all, mustListOptions(t, tt.query+"&pageSize=100"))
+		if err != nil {
+			t.Fatalf("%q: %v", tt.query, err)
+		}
+		if page.TotalCount != tt.count || len(page.Complaints) != tt.count {
+			t.Errorf("%q: %d complaints, total_count %d, want %d", tt.query, len(page.Complaints), page.TotalCount, tt.count)
+		}
+		if page.Total != len(all) {
+			t.Errorf("%q: total %d, want %d", tt.query, page.Total, len(all))
+		}
+		for _, c := range page.Complaints {
+			if !tt.check(c) {
+				t.Errorf("%q: complaint %+v does not match the filter", tt.query, c)
+			}
+		}
+	}
+}
+
+func TestListComplaintsSortIsStable(t *testing.T) {
+	all := seedComplaints(36)
+
+	page, err := listComplaints(all, mustListOptions(t, "sort=severity&pageSize=100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(page.Complaints); i++ {
+		prev, cur := page.Complaints[i-1], page.Complaints[i]
+		if prev.Severity < cur.Severity {
+			t.Fatalf("severity order broken at %d: %v", i, ids(page.Complaints))
+		}
+		// Equal severities keep ID order whatever the input order.
+		if prev.Severity == cur.Severity && !lessID(prev.ID, cur.ID) {
+			t.Fatalf("ties not in ID order at %d: %v", i, ids(page.Complaints))
+		}
+	}
+
+	page, err = listComplaints(all, mustListOptions(t, "sort=id&pageSize=100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range page.Complaints {
+		if c.ID != strconv.Itoa(i+1) {
+			t.Fatalf("sort=id gave %v", ids(page.Complaints))
+		}
+	}
+}
+
+func TestListComplaintsPageBoundaries(t *testing.T) {
+	all := seedComplaints(36)
+
+	tests := []struct {
+		query      string
+		wantIDs    int
+		firstID    string
+		totalPages int
+	}{
+		{"pageSize=10&page=1", 10, "1", 4},
+		{"pageSize=10&page=4", 6, "31", 4},
+		{"pageSize=10&page=5", 0, "", 4},
+		{"pageSize=10&page=0", 0, "", 4},
+		{"pageSize=36&page=1", 36, "1", 1},
+		{"pageSize=1000", 36, "1", 1},
+		{"page=" + strconv.Itoa(math.MaxInt), 0, "", 2},
+		{"page=4611686018427387905", 0, "", 2},
+	}
+
+	for _, tt := range tests {
+		page, err := listComplaints(all, mustListOptions(t, tt.query))
+		if err != nil {
+			t.Fatalf("%q: %v", tt.query, err)
+		}
+		if len(page.Complaints) != tt.wantIDs {
+			t.Errorf("%q: %d complaints, want %d", tt.query, len(page.Complaints), tt.wantIDs)
+		}
+		if tt.firstID != "" && len(page.Complaints) > 0 && page.Complaints[0].ID != tt.firstID {
+			t.Errorf("%q: first complaint %s, want %s", tt.query, page.Complaints[0].ID, tt.firstID)
+		}
+		if page.Complaints == nil {
+			t.Errorf("%q: complaints is nil, want an empty slice", tt.query)
+		}
+		if page.TotalPages != tt.totalPages || page.TotalCount != len(all) || page.Total != len(all) {
+			t.Errorf("%q: total_pages %d, total_count %d, total %d, want %d, %d, %d",
+				tt.query, page.TotalPages, page.TotalCount, page.Total, tt.totalPages, len(all), len(all))
+		}
+	}
+}
+
+func TestHugePageNumbersGiveAnEmptyPage(t *testing.T) {
+	resetState(t)
+	registerUser(t, "user-secret")
+	token := login(t, "user-secret")
+	submitComplaint(t, token, "Noise", 2)
+
+	for _, page := range []string{"9223372036854775807", "4611686018427387905"} {
+		w := request(t, http.MethodGet, "/complaints?page="+page, token, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("page=%s: status %d: %s", page, w.Code, w.Body)
+		}
+		var got complaintPage
+		decode(t, w, &got)
+		if len(got.Complaints) != 0 || got.TotalCount != 1 {
+			t.Errorf("page=%s: %d complaints, total_count %d, want 0, 1", page, len(got.Complaints), got.TotalCount)
+		}
+	}
+}
+
+func TestListingsOverHTTP(t *testing.T) {
+	resetState(t)
+	config.ComplaintsPerUserPerHour = 100
+	_, adminToken := addAdmin(t, "admin-secret")
+	registerUser(t, "user-secret")
+	token := login(t, "user-secret")
+	for i := 1; i <= 25; i++ {
+		submitComplaint(t, token, "Complaint "+strconv.Itoa(i), i%5+1)
+	}
+
+	for target, token := range map[string]string{"/complaints?page=2&pageSize=10": token, "/admin/complaints?page=2&pageSize=10": adminToken} {
+		w := request(t, http.MethodGet, target, token, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", target, w.Code, w.Body)
+		}
+		var page complaintPage
+		decode(t, w, &page)
+		if page.Total != 25 || page.Page != 2 || page.PageSize != 10 || len(page.Complaints) != 10 || page.Complaints[0].ID != "11" {
+			t.Errorf("%s: page %d of size %d, total %d, complaints %v", target, page.Page, page.PageSize, page.Total, ids(page.Complaints))
+		}
+	}
+}
+
+func TestListingsRejectInvalidParameters(t *testing.T) {
+	resetState(t)
+	_, adminToken := addAdmin(t, "admin-secret")
+	registerUser(t, "user-secret")
+	token := login(t, "user-secret")
+
+	for _, query := range []string{"page=x", "pageSize=0", "pageSize=x", "resolved=maybe", "status=closed", "minSeverity=high", "severity=x", "severity_gte=x", "sort=random"} {
+		for target, token := range map[string]string{"/complaints?" + query: token, "/admin/complaints?" + query: adminToken} {
+			if w := request(t, http.MethodGet, target, token, nil); w.Code != http.StatusBadRequest {
+				t.Errorf("%s: status %d, want %d", target, w.Code, http.StatusBadRequest)
+			}
+		}
+	}
+}
+
+func TestListComplaintsFilterCombinations(t *testing.T) {
+	all := seedComplaints(36)
+
+	// Each value is paired with the check it stands for. The last value
+	// of severity and severity_gte matches nothing.
+	resolvedValues := map[string]func(Complaint) bool{
+		"":      func(Complaint) bool { return true },
+		"true":  func(c Complaint) bool { return c.Status == StatusResolved },
+		"false": func(c Complaint) bool { return c.Status != StatusResolved },
+	}
+	severityValues := map[string]func(Complaint) bool{
+		"":  func(Complaint) bool { return true },
+		"3": func(c Complaint) bool { return c.Severity == 3 },
+		"9": func(c Complaint) bool { return false },
+	}
+	severityGTEValues := map[string]func(Complaint) bool{
+		"":  func(Complaint) bool { return true },
+		"2": func(c Complaint) bool { return c.Severity >= 2 },
+		"6": func(c Complaint) bool { return false },
+	}
+	sortValues := map[string]func(a, b Complaint) bool{
+		"severity_desc": func(a, b Complaint) bool {
+			if a.Severity != b.Severity {
+				return a.Severity > b.Severity
+			}
+			return lessID(a.ID, b.ID)
+		},
+		"submitted_asc":  func(a, b Complaint) bool { return lessID(a.ID, b.ID) },
+		"submitted_desc": func(a, b Complaint) bool { return lessID(b.ID, a.ID) },
+	}
+
+	for resolved, matchResolved := range resolvedValues {
+		for severity, matchSeverity := range severityValues {
+			for severityGTE, matchSeverityGTE := range severityGTEValues {
+				for sortOrder, less := range sortValues {
+					query := url.Values{"sort": {sortOrder}, "pageSize": {"100"}}
+					if resolved != "" {
+						query.Set("resolved", resolved)
+					}
+					if severity != "" {
+						query.Set("severity", severity)
+					}
+					if severityGTE != "" {
+						query.Set("severity_gte", severityGTE)
+					}
+
+					want := []Complaint{}
+					for _, c := range all {
+						if matchResolved(c) && matchSeverity(c) && matchSeverityGTE(c) {
+							want = append(want, c)
+						}
+					}
+					sort.Slice(want, func(i, j int) bool { return less(want[i], want[j]) })
+
+					opts, err := parseComplaintListOptions(query)
+					if err != nil {
+						t.Fatalf("%s: %v", query.Encode(), err)
+					}
+					page, err := listComplaints(all, opts)
+					if err != nil {
+						t.Fatalf("%s: %v", query.Encode(), err)
+					}
+
+					if got := ids(page.Complaints); !reflect.DeepEqual(got, ids(want)) {
+						t.Errorf("%s: got %v, want %v", query.Encode(), got, ids(want))
+					}
+					if page.TotalCount != len(want) || page.Total != len(all) {
+						t.Errorf("%s: total_count %d, total %d, want %d, %d",
+							query.Encode(), page.TotalCount, page.Total, len(want), len(all))
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestListingFiltersOnBothEndpoints(t *testing.T) {
+	resetState(t)
+	_, adminToken := addAdmin(t, "admin-secret")
+	registerUser(t, "user-secret")
+	registerUser(t, "other-secret")
+	userToken := login(t, "user-secret")
+	otherToken := login(t, "other-secret")
+
+	submitComplaint(t, userToken, "Low", 1)
+	high := submitComplaint(t, userToken, "High", 5)
+	otherHigh := submitComplaint(t, otherToken, "Other high", 5)
+
+	tests := []struct {
+		target, token string
+		want          []string
+		total         int
+	}{
+		{"/complaints?severity_gte=4", userToken, []string{high}, 2},
+		{"/complaints?minSeverity=4", userToken, []string{high}, 2},
+		{"/complaints?severity=3", userToken, []string{}, 2},
+		{"/admin/complaints?severity_gte=4&sort=submitted_desc", adminToken, []string{otherHigh, high}, 3},
+		{"/admin/complaints?resolved=true", adminToken, []string{}, 3},
+	}
+
+	for _, tt := range tests {
+		w := request(t, http.MethodGet, tt.target, tt.token, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", tt.target, w.Code, w.Body)
+		}
+		var page complaintPage
+		decode(t, w, &page)
+		if got := ids(page.Complaints); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.target, got, tt.want)
+		}
+		if page.Total != tt.total {
+			t.Errorf("%s: total %d, want %d", tt.target, page.Total, tt.total)
+		}
+	}
+
+	w := request(t, http.MethodGet, "/complaints?severity=high", userToken, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("non-numeric severity: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	var body map[string]string
+	decode(t, w, &body)
+	if body["error"] != `invalid severity "high": must be an integer` {
+		t.Errorf("non-numeric severity: error %q", body["error"])
+	}
+}
+
+func TestSearchByResolutionNote(t *testing.T) {
+	resetState(t)
+	_, adminToken := addAdmin(t, "admin-secret")
+	registerUser(t, "user-secret")
+	userToken := login(t, "user-secret")
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	resolved := func(id, note string, minutes int) Complaint {
+		at := base.Add(time.Duration(minutes) * time.Minute)
+		return Complaint{ID: id, Title: "Complaint " + id, Severity: 1, Status: StatusResolved, ResolutionNote: note, ResolvedAt: &at}
+	}
+	mu.Lock()
+	for _, c := range []Complaint{
+		resolved("1", "Fixed the LEAKY pipe", 1),
+		resolved("2", "Pipe replaced", 3),
+		resolved("3", "Nothing to do", 2),
+		{ID: "4", Title: "Complaint 4", Severity: 1, Status: StatusOpen},
+		{ID: "5", Title: "Complaint 5", Severity: 1, Status: StatusOpen, ResolutionNote: "Leak is next door"},
+	} {
+		complaints[c.ID] = c
+	}
+	mu.Unlock()
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"q=pipe", []string{"2", "1"}},
+		{"q=PIPE", []string{"2", "1"}},
+		{"q=%20pipe%20", []string{"2", "1"}},
+		// Complaints without a resolution time sort last.
+		{"q=eak", []string{"1", "5"}},
+		{"q=replace", []string{"2"}},
+		{"q=missing", []string{}},
+		{"q=pipe&page_size=1&page=2", []string{"1"}},
+		// The order is always most recently resolved first.
+		{"q=pipe&sort=id", []string{"2", "1"}},
+	}
+
+	for _, tt := range tests {
+		w := request(t, http.MethodGet, "/admin/complaints/byResolutionNote?"+tt.query, adminToken, nil)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: status %d: %s", tt.query, w.Code, w.Body)
+			continue
+		}
+		var page complaintPage
+		decode(t, w, &page)
+		if got := ids(page.Complaints); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	var page struct {
+		Complaints []map[string]interface{} `json:"complaints"`
+	}
+	decode(t, request(t, http.MethodGet, "/admin/complaints/byResolutionNote?q=replaced", adminToken, nil), &page)
+	if len(page.Complaints) != 1 {
+		t.Fatalf("got %d complaints, want 1", len(page.Complaints))
+	}
+	if got := page.Complaints[0]; got["resolutionNote"] != "Pipe replaced" || got["resolvedAt"] != base.Add(3*time.Minute).Format(time.RFC3339) {
+		t.Errorf("complaint = %v, want its resolution note and time", got)
+	}
+
+	for _, tt := range []struct {
+		query, token string
+		want         int
+	}{
+		{"q=pi", adminToken, http.StatusBadRequest},
+		{"q=%20pi%20", adminToken, http.StatusBadRequest},
+		{"", adminToken, http.StatusBadRequest},
+		{"q=pipe&page=x", adminToken, http.StatusBadRequest},
+		{"q=pipe", userToken, http.StatusForbidden},
+		{"q=pipe", "", http.StatusUnauthorized},
+	} {
+		if w := request(t, http.MethodGet, "/admin/complaints/byResolutionNote?"+tt.query, tt.token, nil); w.Code != tt.want {
+			t.Errorf("%q: status %d, want %d", tt.query, w.Code, tt.want)
+		}
+	}
+}
+
+func TestResolveRecordsNoteAndTime(t *testing.T) {
+	resetState(t)
+	_, adminToken := addAdmin(t, "admin-secret")
+	registerUser(t, "user-secret")
+	id := submitComplaint(t, login(t, "user-secret"), "Noise", 2)
+
+	before := time.Now()
+	if w := request(t, http.MethodPatch, "/complaints/"+id+"/resolve", adminToken, map[string]string{"resolutionNote": "Spoke to the neighbour"}); w.Code != http.StatusNoContent {
+		t.Fatalf("resolving: status %d: %s", w.Code, w.Body)
+	}
+
+	mu.Lock()
+	complaint := complaints[id]
+	mu.Unlock()
+	if complaint.ResolutionNote != "Spoke to the neighbour" || complaint.ResolvedAt == nil || complaint.ResolvedAt.Before(before) {
+		t.Fatalf("resolved complaint = %+v, want the note and a resolution time", complaint)
+	}
+	resolvedAt := *complaint.ResolvedAt
+
+	// A resolved complaint cannot be resolved again, so its note and time
+	// stay as they were.
+	if w := request(t, http.MethodPatch, "/complaints/"+id+"/resolve", adminToken, map[string]string{"resolutionNote": "Changed"}); w.Code != http.StatusConflict {
+		t.Fatalf("resolving again: status %d, want %d: %s", w.Code, http.StatusConflict, w.Body)
+	}
+	mu.Lock()
+	complaint = complaints[id]
+	mu.Unlock()
+	if complaint.ResolutionNote != "Spoke to the neighbour" || !complaint.ResolvedAt.Equal(resolvedAt) {
+		t.Errorf("after resolving again = %+v, want the note and time unchanged", complaint)
+	}
+}
@@ -2,46 +2,118 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
+	"flag"
+	"log"
 	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // User represents a user record
 type User struct {
-	ID         string      `json:"id"`
-	SecretCode string      `json:"secretCode"`
-	Name       string      `json:"name"`
-	Email      string      `json:"email"`
+	ID          string            `json:"id"`
+	SecretCode  string            `json:"-"`
+	Name        string            `json:"name"`
+	Email       string            `json:"email"`
+	Role        string            `json:"role"`
+	Preferences map[string]string `json:"preferences,omitempty"`
+}
+
+// userView is a user as returned to clients, with their complaints
+// assembled from the complaints map.
+type userView struct {
+	User
 	Complaints []Complaint `json:"complaints"`
 }
 
 type Complaint struct {
-	ID         string `json:"id"`
-	Title      string `json:"title"`
-	Summary    string `json:"summary"`
-	Severity   int    `json:"severity"`
-	Resolved   bool   `json:"resolved"`
-	SecretCode string
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Summary  string `json:"summary"`
+	Severity int    `json:"severity"`
+	UserID   string `json:"userId"`
+
+	Status ComplaintStatus `json:"status"`
+	// StatusChangedBy is who last changed the status, as "admin:<id>" or
+	// "user:<id>".
+	StatusChangedBy string    `json:"statusChangedBy,omitempty"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+
+	ResolutionNote string     `json:"resolutionNote,omitempty"`
+	ResolvedAt     *time.Time `json:"resolvedAt,omitempty"`
 }
 
 var mu sync.Mutex
 
 var users = make(map[string]User)
 
+// secretCodesByID maps each user ID to the key of that user in users, so
+// users can be found by ID without scanning the map. It is guarded by mu
+// and only changed through putUser, deleteUser and setUsers.
+var secretCodesByID = make(map[string]string)
+
 var complaints = make(map[string]Complaint)
 
+// lastUserID and lastComplaintID are the most recently issued IDs for each
+// entity. They only ever increase and are persisted with the store so IDs
+// keep increasing across restarts.
+var (
+	lastUserID      atomic.Int64
+	lastComplaintID atomic.Int64
+)
+
+// dataFile is the path of the JSON file the state is persisted to.
+var dataFile string
+
 func main() {
-	http.HandleFunc("/login", loginHandler)
-	http.HandleFunc("/register", registerHandler)
-	http.HandleFunc("/submitComplaint", submitComplaintHandler)
-	http.HandleFunc("/getAllComplaintsForUser", getAllComplaintsForUserHandler)
-	http.HandleFunc("/getAllComplaintsForAdmin", getAllComplaintsForAdminHandler)
-	http.HandleFunc("/viewComplaint", viewComplaintHandler)
-	http.HandleFunc("/resolveComplaint", resolveComplaintHandler)
+	defaultDataFile := os.Getenv("DATA_PATH")
+	if defaultDataFile == "" {
+		defaultDataFile = os.Getenv("DATA_FILE")
+	}
+	if defaultDataFile == "" {
+		defaultDataFile = "data.json"
+	}
 
-	fmt.Println("Server is running on :8080...")
-	http.ListenAndServe(":8080", nil)
+	defaultAddr := ":8080"
+	if port := os.Getenv("PORT"); port != "" {
+		defaultAddr = ":" + port
+	}
+
+	var addr string
+	flag.StringVar(&dataFile, "data", defaultDataFile, "path to the JSON data file")
+	flag.StringVar(&addr, "addr", defaultAddr, "address to listen on")
+	flag.Parse()
+
+	if err := loadConfig(); err != nil {
+		log.Fatal(err)
+	}
+
+	var store Store
+	if err := store.Load(dataFile); err != nil {
+		log.Fatalf("loading %s: %v", dataFile, err)
+	}
+	setUsers(store.Users)
+	complaints = store.Complaints
+	auditLog = store.AuditLog
+	lastUserID.Store(store.LastUserID)
+	lastComplaintID.Store(store.LastComplaintID)
+
+	if secretCode := os.Getenv("ADMIN_SECRET"); secretCode != "" {
+		if err := bootstrapAdmin(secretCode); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	server := newServer(addr, NewRouter())
+	if err := run(server); err != nil {
+		log.Fatal(err)
+	}
 }
 
 func writeError(w http.ResponseWriter, errMsg string, statusCode int) {
@@ -51,8 +123,57 @@ func writeError(w http.ResponseWriter, errMsg string, statusCode int) {
 	json.NewEncoder(w).Encode(errorMessage)
 }
 
-func generateUniqueID() string {
-	return fmt.Sprintf("%d", len(complaints)+1)
+// nextUserID returns a new user ID. It is safe for concurrent use and never
+// returns the same value twice.
+func nextUserID() string {
+	return strconv.FormatInt(lastUserID.Add(1), 10)
+}
+
+// nextComplaintID returns a new complaint ID. It is safe for concurrent use
+// and never returns the same value twice.
+func nextComplaintID() string {
+	return strconv.FormatInt(lastComplaintID.Add(1), 10)
+}
+
+// putUser adds or replaces user in users. Callers must hold mu.
+func putUser(user User) {
+	users[user.SecretCode] = user
+	secretCodesByID[user.ID] = user.SecretCode
+}
+
+// deleteUser removes user from users. Callers must hold mu.
+func deleteUser(user User) {
+	delete(users, user.SecretCode)
+	delete(secretCodesByID, user.ID)
+}
+
+// setUsers replaces every user with those in all. Callers must hold mu.
+func setUsers(all map[string]User) {
+	users = all
+	secretCodesByID = make(map[string]string, len(all))
+	for secretCode, user := range all {
+		secretCodesByID[user.ID] = secretCode
+	}
+}
+
+// userComplaints returns the complaints submitted by userID in submission
+// order. Callers must hold mu.
+func userComplaints(userID string) []Complaint {
+	owned := []Complaint{}
+	for _, complaint := range complaints {
+		if complaint.UserID == userID {
+			owned = append(owned, complaint)
+		}
+	}
+	sort.Slice(owned, func(i, j int) bool {
+		return lessID(owned[i].ID, owned[j].ID)
+	})
+	return owned
+}
+
+// viewUser assembles the client view of user. Callers must hold mu.
+func viewUser(user User) userView {
+	return userView{User: user, Complaints: userComplaints(user.ID)}
 }
 
 func loginHandler(w http.ResponseWriter, r *http.Request) {
@@ -68,64 +189,131 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var response struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expiresAt"`
+		User      userView  `json:"user"`
+	}
+
 	user, exists := users[credentials.SecretCode]
 	if !exists {
 		writeError(w, "User not found", http.StatusNotFound)
 		return
 	}
 
-	json.NewEncoder(w).Encode(user)
+	s := session{userID: user.ID, expiresAt: time.Now().Add(sessionTTL)}
+	response.User = viewUser(user)
+
+	token, err := newSession(s)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response.Token = token
+	response.ExpiresAt = s.expiresAt
+
+	json.NewEncoder(w).Encode(response)
 }
 
 func registerHandler(w http.ResponseWriter, r *http.Request) {
 	mu.Lock()
 	defer mu.Unlock()
 
-	var newUser User
+	var request struct {
+		SecretCode string `json:"secretCode"`
+		Name       string `json:"name"`
+		Email      string `json:"email"`
+	}
 
-	if err := json.NewDecoder(r.Body).Decode(&newUser); err != nil {
+	if err := decodeStrict(r, &request); err != nil {
 		writeError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	newUser := User{
+		SecretCode: request.SecretCode,
+		Name:       request.Name,
+		Email:      request.Email,
+		Role:       RoleUser,
+	}
+
+	if errs := validate(newUser); len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+
 	if _, exists := users[newUser.SecretCode]; exists {
 		writeError(w, "Secret code already in use", http.StatusBadRequest)
 		return
 	}
 
-	newUser.ID = generateUniqueID()
+	newUser.ID = nextUserID()
 
-	newUser.Complaints = []Complaint{}
+	putUser(newUser)
 
-	users[newUser.SecretCode] = newUser
+	if err := saveState(); err != nil {
+		deleteUser(newUser)
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	json.NewEncoder(w).Encode(newUser)
+	json.NewEncoder(w).Encode(viewUser(newUser))
 }
 
 func submitComplaintHandler(w http.ResponseWriter, r *http.Request) {
 	mu.Lock()
 	defer mu.Unlock()
 
-	var newComplaint Complaint
+	var request struct {
+		Title    string `json:"title"`
+		Summary  string `json:"summary"`
+		Severity int    `json:"severity"`
+	}
 
-	if err := json.NewDecoder(r.Body).Decode(&newComplaint); err != nil {
+	if err := decodeStrict(r, &request); err != nil {
 		writeError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	newComplaint := Complaint{
+		Title:    request.Title,
+		Summary:  request.Summary,
+		Severity: request.Severity,
+	}
+
+	if errs := validate(newComplaint); len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+
 	// Check if the user exists
-	user, exists := users[newComplaint.SecretCode]
+	user, exists := findUserByID(authUserID(r))
 	if !exists {
-		writeError(w, "User not found", http.StatusNotFound)
+		writeError(w, "User not found", http.StatusUnauthorized)
 		return
 	}
 
-	newComplaint.ID = generateUniqueID()
+	if ok, resetAt := allowComplaint(user.ID); !ok {
+		writeRateLimited(w, resetAt)
+		return
+	}
+
+	now := time.Now()
+	newComplaint.ID = nextComplaintID()
+	newComplaint.UserID = user.ID
+	newComplaint.Status = StatusOpen
+	newComplaint.CreatedAt = now
+	newComplaint.UpdatedAt = now
 
 	complaints[newComplaint.ID] = newComplaint
 
-	user.Complaints = append(user.Complaints, newComplaint)
-	users[newComplaint.SecretCode] = user
+	// A failed save undoes the change, so memory never holds a complaint
+	// the data file does not.
+	if err := saveState(); err != nil {
+		delete(complaints, newComplaint.ID)
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	w.WriteHeader(http.StatusCreated)
 }
@@ -134,117 +322,242 @@ func getAllComplaintsForUserHandler(w http.ResponseWriter, r *http.Request) {
 	mu.Lock()
 	defer mu.Unlock()
 
-	var user struct {
-		SecretCode string `json:"secretCode"`
+	// Check if the user exists
+	userDetails, exists := findUserByID(authUserID(r))
+	if !exists {
+		writeError(w, "User not found", http.StatusUnauthorized)
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+	opts, err := parseComplaintListOptions(r.URL.Query())
+	if err != nil {
 		writeError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Check if the user exists
-	userDetails, exists := users[user.SecretCode]
-	if !exists {
-		writeError(w, "User not found", http.StatusNotFound)
+	// Return the requested page of the user's complaints
+	page, err := listComplaints(userComplaints(userDetails.ID), opts)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Return all complaints for the user
-	json.NewEncoder(w).Encode(userDetails.Complaints)
+	json.NewEncoder(w).Encode(page)
 }
 
 func getAllComplaintsForAdminHandler(w http.ResponseWriter, r *http.Request) {
 	mu.Lock()
 	defer mu.Unlock()
 
-	var adminCredentials struct {
-		SecretCode string `json:"secretCode"`
+	if _, ok := authorizeAdmin(w, r, legacySecretCode(r)); !ok {
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&adminCredentials); err != nil {
+	opts, err := parseComplaintListOptions(r.URL.Query())
+	if err != nil {
 		writeError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if adminCredentials.SecretCode != "admin" {
-		writeError(w, "Unauthorized", http.StatusUnauthorized)
-		return
+	// Return the requested page of all complaints for administrators
+	var allComplaints []Complaint
+	for _, complaint := range complaints {
+		allComplaints = append(allComplaints, complaint)
 	}
 
-	// Return all complaints for administrators
-	var allComplaints []Complaint
-	for _, user := range users {
-		allComplaints = append(allComplaints, user.Complaints...)
+	page, err := listComplaints(allComplaints, opts)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	json.NewEncoder(w).Encode(allComplaints)
+	json.NewEncoder(w).Encode(page)
 }
 
-func viewComplaintHandler(w http.ResponseWriter, r *http.Request) {
+// adminComplaintsByResolutionNoteHandler searches resolution notes for the
+// q query parameter, most recently resolved first.
+func adminComplaintsByResolutionNoteHandler(w http.ResponseWriter, r *http.Request) {
 	mu.Lock()
 	defer mu.Unlock()
 
-	var complaint struct {
-		ID string `json:"id"`
+	if _, ok := authorizeAdmin(w, r, legacySecretCode(r)); !ok {
+		return
+	}
+
+	q := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("q")))
+	if len(q) < 3 {
+		writeError(w, "q must be at least 3 characters", http.StatusBadRequest)
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&complaint); err != nil {
+	opts, err := parseComplaintListOptions(r.URL.Query())
+	if err != nil {
 		writeError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	opts.Sort = sortResolvedDesc
 
-	complaintDetails, exists := complaints[complaint.ID]
-	if !exists {
-		writeError(w, "Complaint not found", http.StatusNotFound)
+	var matches []Complaint
+	for _, complaint := range complaints {
+		if strings.Contains(strings.ToLower(complaint.ResolutionNote), q) {
+			matches = append(matches, complaint)
+		}
+	}
+
+	page, err := listComplaints(matches, opts)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	_, exists = users[complaintDetails.SecretCode]
-	if !exists {
-		writeError(w, "User not found", http.StatusNotFound)
+	json.NewEncoder(w).Encode(page)
+}
+
+func viewComplaintHandler(w http.ResponseWriter, r *http.Request) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	complaintDetails, ok := ownComplaint(w, r)
+	if !ok {
 		return
 	}
 
 	json.NewEncoder(w).Encode(complaintDetails)
 }
 
-func resolveComplaintHandler(w http.ResponseWriter, r *http.Request) {
+func updateComplaintHandler(w http.ResponseWriter, r *http.Request) {
 	mu.Lock()
 	defer mu.Unlock()
 
-	var complaint struct {
-		ID string `json:"id"`
+	// id and secretCode are only sent to the /updateComplaint alias, which
+	// reads them before this handler runs.
+	var update struct {
+		ID         string `json:"id"`
+		SecretCode string `json:"secretCode"`
+		Title      string `json:"title"`
+		Summary    string `json:"summary"`
+		Severity   int    `json:"severity"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&complaint); err != nil {
+	if err := decodeStrict(r, &update); err != nil {
 		writeError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	var adminCredentials struct {
-		SecretCode string `json:"secretCode"`
+	complaintDetails, ok := ownComplaint(w, r)
+	if !ok {
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&adminCredentials); err != nil {
-		writeError(w, err.Error(), http.StatusBadRequest)
+	if complaintDetails.Status.closed() {
+		writeError(w, "Complaint is already closed", http.StatusConflict)
 		return
 	}
 
-	if adminCredentials.SecretCode != "admin" {
-		writeError(w, "Unauthorized", http.StatusUnauthorized)
+	original := complaintDetails
+
+	// Only the fields that were given are changed
+	if update.Title != "" {
+		complaintDetails.Title = update.Title
+	}
+	if update.Summary != "" {
+		complaintDetails.Summary = update.Summary
+	}
+	if update.Severity != 0 {
+		complaintDetails.Severity = update.Severity
+	}
+	complaintDetails.UpdatedAt = time.Now()
+
+	if errs := validate(complaintDetails); len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	complaints[complaintDetails.ID] = complaintDetails
+
+	if err := saveState(); err != nil {
+		complaints[original.ID] = original
+		writeError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Check if the complaint exists
-	complaintDetails, exists := complaints[complaint.ID]
+	json.NewEncoder(w).Encode(complaintDetails)
+}
+
+// deleteComplaintHandler lets the owner delete an unresolved complaint and
+// an admin delete any complaint.
+func deleteComplaintHandler(w http.ResponseWriter, r *http.Request) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	secretCode := legacySecretCode(r)
+	adminUser, admin := requestAdmin(r, secretCode)
+
+	var requester User
+	if !admin {
+		var exists bool
+		requester, exists = requestUser(r, secretCode)
+		if !exists {
+			writeError(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	complaintDetails, exists := complaints[r.PathValue("id")]
 	if !exists {
 		writeError(w, "Complaint not found", http.StatusNotFound)
 		return
 	}
 
-	complaintDetails.Resolved = true
-	complaints[complaint.ID] = complaintDetails
+	if !admin {
+		if complaintDetails.UserID != requester.ID {
+			writeError(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		if complaintDetails.Status.closed() {
+			writeError(w, "Closed complaints can only be deleted by an admin", http.StatusForbidden)
+			return
+		}
+	}
+
+	delete(complaints, complaintDetails.ID)
+
+	if admin {
+		recordAudit(adminUser.ID, "delete", complaintDetails.ID, complaintDetails.Title)
+	}
+
+	if err := saveState(); err != nil {
+		complaints[complaintDetails.ID] = complaintDetails
+		if admin {
+			dropLastAudit()
+		}
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// ownComplaint looks up the complaint in the request path and checks that it
+// belongs to the authenticated user. On failure it writes the error response
+// and returns false. Callers must hold mu.
+func ownComplaint(w http.ResponseWriter, r *http.Request) (Complaint, bool) {
+	complaintDetails, exists := complaints[r.PathValue("id")]
+	if !exists {
+		writeError(w, "Complaint not found", http.StatusNotFound)
+		return Complaint{}, false
+	}
+
+	user, exists := findUserByID(authUserID(r))
+	if !exists {
+		writeError(w, "User not found", http.StatusUnauthorized)
+		return Complaint{}, false
+	}
+
+	if complaintDetails.UserID != user.ID {
+		writeError(w, "Forbidden", http.StatusForbidden)
+		return Complaint{}, false
+	}
+
+	return complaintDetails, true
+}
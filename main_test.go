@@ -0,0 +1,393 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestNextIDsAreUniqueUnderConcurrency(t *testing.T) {
+	resetState(t)
+
+	const goroutines, perGoroutine = 50, 200
+
+	for name, next := range map[string]func() string{
+		"nextUserID":      nextUserID,
+		"nextComplaintID": nextComplaintID,
+	} {
+		t.Run(name, func(t *testing.T) {
+			var (
+				seenMu sync.Mutex
+				seen   = make(map[string]bool)
+				wg     sync.WaitGroup
+			)
+			for i := 0; i < goroutines; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < perGoroutine; j++ {
+						id := next()
+						seenMu.Lock()
+						if seen[id] {
+							t.Errorf("%s returned %q twice", name, id)
+						}
+						seen[id] = true
+						seenMu.Unlock()
+					}
+				}()
+			}
+			wg.Wait()
+
+			if len(seen) != goroutines*perGoroutine {
+				t.Errorf("got %d distinct IDs, want %d", len(seen), goroutines*perGoroutine)
+			}
+		})
+	}
+}
+
+func TestConcurrentRegisterAndSubmitGiveUniqueIDs(t *testing.T) {
+	resetState(t)
+	config.AuthRequestBurst = 1000
+	config.ComplaintsPerUserPerHour = 1000
+
+	const userCount, complaintsPerUser = 5, 20
+
+	secretCodes := make([]string, userCount)
+	userIDs := make([]string, userCount)
+	var wg sync.WaitGroup
+	for i := range secretCodes {
+		secretCodes[i] = fmt.Sprintf("secret-%02d", i)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := request(t, http.MethodPost, "/users", "", map[string]string{
+				"secretCode": secretCodes[i],
+				"name":       "User",
+				"email":      "user@example.com",
+			})
+			if w.Code != http.StatusOK {
+				t.Errorf("registering: status %d: %s", w.Code, w.Body)
+				return
+			}
+			var user User
+			decode(t, w, &user)
+			userIDs[i] = user.ID
+		}(i)
+	}
+	wg.Wait()
+
+	seenUsers := make(map[string]bool)
+	for _, id := range userIDs {
+		if seenUsers[id] {
+			t.Errorf("user ID %q issued twice", id)
+		}
+		seenUsers[id] = true
+	}
+
+	tokens := make([]string, userCount)
+	for i, secretCode := range secretCodes {
+		tokens[i] = login(t, secretCode)
+	}
+
+	for i := range tokens {
+		for j := 0; j < complaintsPerUser; j++ {
+			wg.Add(1)
+			go func(token string, n int) {
+				defer wg.Done()
+				w := request(t, http.MethodPost, "/complaints", token, map[string]interface{}{
+					"title":    fmt.Sprintf("Complaint %d", n),
+					"summary":  "Summary",
+					"severity": 1,
+				})
+				if w.Code != http.StatusCreated {
+					t.Errorf("submitting: status %d: %s", w.Code, w.Body)
+				}
+			}(tokens[i], j)
+		}
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// Every submission issued one ID, so an overwritten complaint would
+	// leave the map short.
+	if got, want := len(complaints), userCount*complaintsPerUser; got != want {
+		t.Errorf("len(complaints) = %d, want %d", got, want)
+	}
+	for _, secretCode := range secretCodes {
+		if got := len(userComplaints(users[secretCode].ID)); got != complaintsPerUser {
+			t.Errorf("user %s has %d complaints, want %d", secretCode, got, complaintsPerUser)
+		}
+	}
+}
+
+func TestUsersOnlyChangeTheirOwnComplaints(t *testing.T) {
+	resetState(t)
+	registerUser(t, "owner-secret")
+	registerUser(t, "other-secret")
+	ownerToken := login(t, "owner-secret")
+	otherToken := login(t, "other-secret")
+	id := submitComplaint(t, ownerToken, "Noise", 2)
+
+	tests := []struct {
+		method, who, target, token string
+		body                       interface{}
+		want                       int
+	}{
+		{http.MethodPatch, "other user", "/complaints/" + id, otherToken, map[string]string{"title": "Hijacked"}, http.StatusForbidden},
+		{http.MethodDelete, "other user", "/complaints/" + id, otherToken, nil, http.StatusForbidden},
+		{http.MethodPatch, "owner", "/complaints/999", ownerToken, map[string]string{"title": "Missing"}, http.StatusNotFound},
+		{http.MethodDelete, "owner", "/complaints/999", ownerToken, nil, http.StatusNotFound},
+		{http.MethodPatch, "no one", "/complaints/" + id, "", map[string]string{"title": "Anonymous"}, http.StatusUnauthorized},
+		{http.MethodPatch, "owner", "/complaints/" + id, ownerToken, map[string]interface{}{"title": "Loud noise", "severity": 4}, http.StatusOK},
+		{http.MethodDelete, "owner", "/complaints/" + id, ownerToken, nil, http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		w := request(t, tt.method, tt.target, tt.token, tt.body)
+		if w.Code != tt.want {
+			t.Errorf("%s %s as %s: status %d, want %d: %s", tt.method, tt.target, tt.who, w.Code, tt.want, w.Body)
+		}
+	}
+}
+
+func TestUpdateComplaintChangesOnlyGivenFields(t *testing.T) {
+	resetState(t)
+	registerUser(t, "owner-secret")
+	token := login(t, "owner-secret")
+	id := submitComplaint(t, token, "Noise", 2)
+
+	w := request(t, http.MethodPatch, "/complaints/"+id, token, map[string]interface{}{"severity": 4})
+	if w.Code != http.StatusOK {
+		t.Fatalf("updating: status %d: %s", w.Code, w.Body)
+	}
+	var updated Complaint
+	decode(t, w, &updated)
+	if updated.Title != "Noise" || updated.Summary != "Summary of Noise" || updated.Severity != 4 || updated.Status != StatusOpen {
+		t.Errorf("updated complaint = %+v, want only the severity changed", updated)
+	}
+
+	var page complaintPage
+	decode(t, request(t, http.MethodGet, "/complaints", token, nil), &page)
+	if len(page.Complaints) != 1 || page.Complaints[0] != updated {
+		t.Errorf("user's listing = %+v, want %+v", page.Complaints, updated)
+	}
+}
+
+func TestResolvedComplaintsAreLocked(t *testing.T) {
+	resetState(t)
+	_, adminToken := addAdmin(t, "admin-secret")
+	registerUser(t, "owner-secret")
+	ownerToken := login(t, "owner-secret")
+	id := submitComplaint(t, ownerToken, "Noise", 2)
+
+	if w := request(t, http.MethodPatch, "/complaints/"+id+"/resolve", adminToken, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("resolving: status %d: %s", w.Code, w.Body)
+	}
+
+	if w := request(t, http.MethodPatch, "/complaints/"+id, ownerToken, map[string]string{"title": "Changed"}); w.Code != http.StatusConflict {
+		t.Errorf("editing a resolved complaint: status %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	mu.Lock()
+	complaint := complaints[id]
+	mu.Unlock()
+	if complaint.Title != "Noise" {
+		t.Errorf("resolved complaint changed to %+v", complaint)
+	}
+}
+
+func TestWithdrawnComplaintIsGoneEverywhere(t *testing.T) {
+	resetState(t)
+	_, adminToken := addAdmin(t, "admin-secret")
+	registerUser(t, "owner-secret")
+	ownerToken := login(t, "owner-secret")
+	kept := submitComplaint(t, ownerToken, "Kept", 1)
+	withdrawn := submitComplaint(t, ownerToken, "Withdrawn", 2)
+
+	if w := request(t, http.MethodDelete, "/complaints/"+withdrawn, ownerToken, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("withdrawing: status %d: %s", w.Code, w.Body)
+	}
+
+	mu.Lock()
+	_, inMap := complaints[withdrawn]
+	owned := ids(userComplaints(users["owner-secret"].ID))
+	mu.Unlock()
+	if inMap {
+		t.Error("withdrawn complaint is still in the complaints map")
+	}
+	if !reflect.DeepEqual(owned, []string{kept}) {
+		t.Errorf("user's complaints = %v, want [%s]", owned, kept)
+	}
+
+	for target, token := range map[string]string{"/complaints": ownerToken, "/admin/complaints": adminToken} {
+		var page complaintPage
+		decode(t, request(t, http.MethodGet, target, token, nil), &page)
+		if got := ids(page.Complaints); !reflect.DeepEqual(got, []string{kept}) {
+			t.Errorf("%s lists %v, want [%s]", target, got, kept)
+		}
+	}
+
+	if w := request(t, http.MethodGet, "/complaints/"+withdrawn, ownerToken, nil); w.Code != http.StatusNotFound {
+		t.Errorf("viewing the withdrawn complaint: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestResolvedComplaintIsResolvedEverywhere(t *testing.T) {
+	resetState(t)
+	_, adminToken := addAdmin(t, "admin-secret")
+	ownerID := registerUser(t, "owner-secret")
+	ownerToken := login(t, "owner-secret")
+	id := submitComplaint(t, ownerToken, "Noise", 2)
+
+	w := request(t, http.MethodPatch, "/complaints/"+id+"/resolve", adminToken, map[string]string{"resolutionNote": "Fixed"})
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("resolving: status %d: %s", w.Code, w.Body)
+	}
+
+	check := func(where string, listed []Complaint) {
+		t.Helper()
+		if len(listed) != 1 {
+			t.Fatalf("%s lists %d complaints, want 1", where, len(listed))
+		}
+		c := listed[0]
+		if c.ID != id || c.Status != StatusResolved || c.ResolutionNote != "Fixed" || c.ResolvedAt == nil {
+			t.Errorf("%s shows %+v, want it resolved with the note", where, c)
+		}
+		if c.UserID != ownerID {
+			t.Errorf("%s shows owner %q, want %q", where, c.UserID, ownerID)
+		}
+	}
+
+	for _, target := range []string{"/complaints", "/getAllComplaintsForUser"} {
+		var page complaintPage
+		decode(t, request(t, http.MethodGet, target, ownerToken, nil), &page)
+		check(target, page.Complaints)
+	}
+	for _, target := range []string{"/admin/complaints", "/getAllComplaintsForAdmin?secretCode=admin-secret"} {
+		var page complaintPage
+		decode(t, request(t, http.MethodGet, target, adminToken, nil), &page)
+		check(target, page.Complaints)
+	}
+
+	var viewed Complaint
+	decode(t, request(t, http.MethodGet, "/complaints/"+id, ownerToken, nil), &viewed)
+	check("/complaints/"+id, []Complaint{viewed})
+
+	var loggedIn struct {
+		User userView `json:"user"`
+	}
+	decode(t, request(t, http.MethodPost, "/login", "", map[string]string{"secretCode": "owner-secret"}), &loggedIn)
+	check("/login", loggedIn.User.Complaints)
+}
+
+func TestUpdateComplaint(t *testing.T) {
+	resetState(t)
+	_, adminToken := addAdmin(t, "admin-secret")
+	registerUser(t, "owner-secret")
+	registerUser(t, "other-secret")
+	ownerToken := login(t, "owner-secret")
+	id := submitComplaint(t, ownerToken, "Noise", 2)
+	resolved := submitComplaint(t, ownerToken, "Leak", 3)
+	if w := request(t, http.MethodPatch, "/complaints/"+resolved+"/resolve", adminToken, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("resolving: status %d: %s", w.Code, w.Body)
+	}
+
+	mu.Lock()
+	before := complaints[id]
+	mu.Unlock()
+
+	tests := []struct {
+		name  string
+		token string
+		body  map[string]interface{}
+		want  int
+	}{
+		{"unknown secret code", "", map[string]interface{}{"id": id, "secretCode": "nobody-secret", "severity": 4}, http.StatusUnauthorized},
+		{"no credentials", "", map[string]interface{}{"id": id, "severity": 4}, http.StatusUnauthorized},
+		{"another user's complaint", "", map[string]interface{}{"id": id, "secretCode": "other-secret", "severity": 4}, http.StatusForbidden},
+		{"resolved complaint", "", map[string]interface{}{"id": resolved, "secretCode": "owner-secret", "severity": 4}, http.StatusConflict},
+		{"invalid severity", "", map[string]interface{}{"id": id, "secretCode": "owner-secret", "severity": 9}, http.StatusUnprocessableEntity},
+		{"missing complaint", "", map[string]interface{}{"id": "999", "secretCode": "owner-secret", "severity": 4}, http.StatusNotFound},
+		{"severity only", "", map[string]interface{}{"id": id, "secretCode": "owner-secret", "severity": 4}, http.StatusOK},
+		{"bearer token", ownerToken, map[string]interface{}{"id": id, "summary": "Every night"}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		w := request(t, http.MethodPatch, "/updateComplaint", tt.token, tt.body)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+	}
+
+	mu.Lock()
+	after := complaints[id]
+	mu.Unlock()
+	if after.Severity != 4 || after.Summary != "Every night" {
+		t.Errorf("severity %d, summary %q, want 4, %q", after.Severity, after.Summary, "Every night")
+	}
+	if after.Title != before.Title {
+		t.Errorf("partial update changed the title: %+v", after)
+	}
+}
+
+func TestDeleteComplaint(t *testing.T) {
+	resetState(t)
+	_, adminToken := addAdmin(t, "admin-secret")
+	registerUser(t, "owner-secret")
+	ownerToken := login(t, "owner-secret")
+	open := submitComplaint(t, ownerToken, "Open", 2)
+	resolved := submitComplaint(t, ownerToken, "Resolved", 3)
+	other := submitComplaint(t, ownerToken, "Other", 1)
+	kept := submitComplaint(t, ownerToken, "Kept", 1)
+	if w := request(t, http.MethodPatch, "/complaints/"+resolved+"/resolve", adminToken, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("resolving: status %d: %s", w.Code, w.Body)
+	}
+
+	tests := []struct {
+		name, id, secretCode string
+		want                 int
+		deleted              bool
+	}{
+		{"unknown secret code", other, "nobody-secret", http.StatusUnauthorized, false},
+		{"no secret code", other, "", http.StatusUnauthorized, false},
+		{"owner deletes a resolved complaint", resolved, "owner-secret", http.StatusForbidden, false},
+		{"owner deletes an unresolved complaint", open, "owner-secret", http.StatusNoContent, true},
+		{"admin deletes a resolved complaint", resolved, "admin-secret", http.StatusNoContent, true},
+	}
+
+	for _, tt := range tests {
+		w := request(t, http.MethodDelete, "/deleteComplaint", "", map[string]string{"id": tt.id, "secretCode": tt.secretCode})
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+
+		mu.Lock()
+		_, exists := complaints[tt.id]
+		mu.Unlock()
+		if exists == tt.deleted {
+			t.Errorf("%s: complaint exists = %v, want %v", tt.name, exists, !tt.deleted)
+		}
+	}
+
+	if w := request(t, http.MethodDelete, "/deleteComplaint", "", map[string]string{"id": "999", "secretCode": "admin-secret"}); w.Code != http.StatusNotFound {
+		t.Errorf("deleting a missing complaint: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	// The REST route follows the same rules with bearer tokens.
+	if w := request(t, http.MethodDelete, "/complaints/"+other, adminToken, nil); w.Code != http.StatusNoContent {
+		t.Errorf("admin deleting with a token: status %d: %s", w.Code, w.Body)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got := ids(userComplaints(users["owner-secret"].ID)); !reflect.DeepEqual(got, []string{kept}) {
+		t.Errorf("owner's complaints = %v, want [%s]", got, kept)
+	}
+	if len(complaints) != 1 {
+		t.Errorf("complaints = %v, want only %s", complaints, kept)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// startedAt is when the process started, for the uptime in /metrics.
+var startedAt = time.Now()
+
+// requestMetrics counts served requests. It has its own lock so that
+// recording a request never waits on mu.
+type requestMetrics struct {
+	mu            sync.Mutex
+	total         int64
+	byEndpoint    map[string]int64
+	byStatusClass map[string]int64
+}
+
+var metrics = &requestMetrics{
+	byEndpoint:    make(map[string]int64),
+	byStatusClass: make(map[string]int64),
+}
+
+func (m *requestMetrics) record(endpoint string, status int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.total++
+	m.byEndpoint[endpoint]++
+	m.byStatusClass[strconv.Itoa(status/100)+"xx"]++
+}
+
+// statusRecorder captures the status code and body size written by a
+// handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.size += n
+	return n, err
+}
+
+// logRequests logs one key=value line per request served by mux and
+// counts it in metrics under the route pattern it matched.
+func logRequests(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, endpoint := mux.Handler(r)
+		rec := &statusRecorder{ResponseWriter: w}
+		start := time.Now()
+
+		mux.ServeHTTP(rec, r)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		duration := time.Since(start)
+		metrics.record(endpoint, rec.status)
+		log.Printf("method=%s path=%q endpoint=%q status=%d duration=%s size=%d",
+			r.Method, r.URL.Path, endpoint, rec.status, duration, rec.size)
+	})
+}
+
+// metricsHandler reports request counters along with user and complaint
+// totals.
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	type complaintCounts struct {
+		Total      int `json:"total"`
+		Open       int `json:"open"`
+		InProgress int `json:"inProgress"`
+		Resolved   int `json:"resolved"`
+		Rejected   int `json:"rejected"`
+	}
+
+	var response struct {
+		UptimeSeconds int64            `json:"uptimeSeconds"`
+		Requests      int64            `json:"requests"`
+		ByEndpoint    map[string]int64 `json:"requestsByEndpoint"`
+		ByStatusClass map[string]int64 `json:"requestsByStatusClass"`
+		Users         int              `json:"users"`
+		Complaints    complaintCounts  `json:"complaints"`
+	}
+
+	metrics.mu.Lock()
+	response.Requests = metrics.total
+	response.ByEndpoint = make(map[string]int64, len(metrics.byEndpoint))
+	for endpoint, n := range metrics.byEndpoint {
+		response.ByEndpoint[endpoint] = n
+	}
+	response.ByStatusClass = make(map[string]int64, len(metrics.byStatusClass))
+	for class, n := range metrics.byStatusClass {
+		response.ByStatusClass[class] = n
+	}
+	metrics.mu.Unlock()
+
+	mu.Lock()
+	response.Users = len(users)
+	response.Complaints.Total = len(complaints)
+	for _, complaint := range complaints {
+		switch complaint.Status {
+		case StatusOpen:
+			response.Complaints.Open++
+		case StatusInProgress:
+			response.Complaints.InProgress++
+		case StatusResolved:
+			response.Complaints.Resolved++
+		case StatusRejected:
+			response.Complaints.Rejected++
+		}
+	}
+	mu.Unlock()
+
+	response.UptimeSeconds = int64(time.Since(startedAt).Seconds())
+
+	json.NewEncoder(w).Encode(response)
+}
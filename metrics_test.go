@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// metricsResponse is the body of GET /metrics.
+type metricsResponse struct {
+	Requests      int64            `json:"requests"`
+	ByEndpoint    map[string]int64 `json:"requestsByEndpoint"`
+	ByStatusClass map[string]int64 `json:"requestsByStatusClass"`
+	Users         int              `json:"users"`
+	Complaints    map[string]int   `json:"complaints"`
+}
+
+func getMetrics(t *testing.T) metricsResponse {
+	t.Helper()
+
+	var m metricsResponse
+	decode(t, request(t, http.MethodGet, "/metrics", "", nil), &m)
+	return m
+}
+
+func TestStatusRecorderCapturesWriteError(t *testing.T) {
+	w := httptest.NewRecorder()
+	rec := &statusRecorder{ResponseWriter: w}
+
+	writeError(rec, "Not found", http.StatusNotFound)
+
+	if rec.status != http.StatusNotFound || w.Code != http.StatusNotFound {
+		t.Errorf("status %d, written %d, want %d", rec.status, w.Code, http.StatusNotFound)
+	}
+	if rec.size == 0 || rec.size != w.Body.Len() {
+		t.Errorf("size %d, want the %d bytes written", rec.size, w.Body.Len())
+	}
+}
+
+func TestLogRequestsRecordsNotFound(t *testing.T) {
+	resetState(t)
+
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(io.Discard) })
+
+	before := getMetrics(t)
+	logged.Reset()
+
+	w := request(t, http.MethodGet, "/no/such/route", "", nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	line := logged.String()
+	for _, want := range []string{
+		"method=GET",
+		`path="/no/such/route"`,
+		`endpoint="/"`,
+		"status=404",
+		fmt.Sprintf("size=%d", w.Body.Len()),
+		"duration=",
+	} {
+		if !strings.Contains(line, want) {
+			t.Errorf("log line %q does not contain %s", line, want)
+		}
+	}
+
+	after := getMetrics(t)
+	if got := after.ByStatusClass["4xx"] - before.ByStatusClass["4xx"]; got != 1 {
+		t.Errorf("4xx count went up by %d, want 1", got)
+	}
+	if got := after.ByEndpoint["/"] - before.ByEndpoint["/"]; got != 1 {
+		t.Errorf("count for / went up by %d, want 1", got)
+	}
+}
+
+func TestMetricsCountRequests(t *testing.T) {
+	resetState(t)
+
+	before := getMetrics(t)
+
+	registerUser(t, "user-secret")
+	token := login(t, "user-secret")
+	submitComplaint(t, token, "Noise", 2)
+	request(t, http.MethodGet, "/complaints/99", token, nil)
+
+	// The first GET /metrics is counted once it has been served, so it is
+	// part of the difference too.
+	after := getMetrics(t)
+	if got := after.Requests - before.Requests; got != 5 {
+		t.Errorf("requests went up by %d, want 5", got)
+	}
+	for endpoint, want := range map[string]int64{
+		"/users":           1,
+		"/login":           1,
+		"/complaints":      1,
+		"/complaints/{id}": 1,
+		"/metrics":         1,
+	} {
+		if got := after.ByEndpoint[endpoint] - before.ByEndpoint[endpoint]; got != want {
+			t.Errorf("count for %s went up by %d, want %d", endpoint, got, want)
+		}
+	}
+	if got := after.ByStatusClass["2xx"] - before.ByStatusClass["2xx"]; got != 4 {
+		t.Errorf("2xx count went up by %d, want 4", got)
+	}
+	if got := after.ByStatusClass["4xx"] - before.ByStatusClass["4xx"]; got != 1 {
+		t.Errorf("4xx count went up by %d, want 1", got)
+	}
+	if after.Users != 1 || after.Complaints["total"] != 1 || after.Complaints["open"] != 1 {
+		t.Errorf("users %d, complaints %v, want 1 user and 1 open complaint", after.Users, after.Complaints)
+	}
+}
+
+func TestMetricsAreSafeForConcurrentRequests(t *testing.T) {
+	resetState(t)
+
+	const n = 50
+	before := getMetrics(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			request(t, http.MethodGet, "/no/such/route", "", nil)
+		}()
+	}
+	wg.Wait()
+
+	after := getMetrics(t)
+	if got := after.ByEndpoint["/"] - before.ByEndpoint["/"]; got != n {
+		t.Errorf("count for / went up by %d, want %d", got, n)
+	}
+}
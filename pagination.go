@@ -0,0 +1,33 @@
+package main
+
+import "fmt"
+
+// paginate returns the 1-based page of complaints holding pageSize items.
+// Pages outside the available range are empty rather than an error.
+func paginate(complaints []Complaint, page, pageSize int) ([]Complaint, error) {
+	if pageSize < 1 {
+		return nil, fmt.Errorf("invalid page size %d: must be at least 1", pageSize)
+	}
+
+	// Checking the page against the page count first keeps the start index
+	// from overflowing on huge page numbers.
+	if page < 1 || page > totalPages(len(complaints), pageSize) {
+		return []Complaint{}, nil
+	}
+
+	start := (page - 1) * pageSize
+
+	end := start + pageSize
+	if end > len(complaints) {
+		end = len(complaints)
+	}
+	return complaints[start:end], nil
+}
+
+// totalPages returns the number of pages needed to hold count items.
+func totalPages(count, pageSize int) int {
+	if count == 0 {
+		return 0
+	}
+	return (count-1)/pageSize + 1
+}
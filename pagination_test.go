@@ -0,0 +1,97 @@
+package main
+
+import (
+	"math"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func numberedComplaints(n int) []Complaint {
+	all := make([]Complaint, n)
+	for i := range all {
+		all[i] = Complaint{ID: strconv.Itoa(i + 1)}
+	}
+	return all
+}
+
+func TestPaginate(t *testing.T) {
+	tests := []struct {
+		name           string
+		count          int
+		page, pageSize int
+		want           []string
+	}{
+		{"page 1 of 1", 3, 1, 20, []string{"1", "2", "3"}},
+		{"exactly one full page", 3, 1, 3, []string{"1", "2", "3"}},
+		{"middle page", 7, 2, 3, []string{"4", "5", "6"}},
+		{"last page with a partial slice", 7, 3, 3, []string{"7"}},
+		{"page past the end", 7, 4, 3, []string{}},
+		{"page 0", 7, 0, 3, []string{}},
+		{"negative page", 7, -1, 3, []string{}},
+		{"no complaints", 0, 1, 20, []string{}},
+		{"largest page", 7, math.MaxInt, 20, []string{}},
+		{"page whose start wraps around to 0", 7, 1<<62 + 1, 20, []string{}},
+		{"largest page size", 7, 1, math.MaxInt, []string{"1", "2", "3", "4", "5", "6", "7"}},
+	}
+
+	for _, tt := range tests {
+		got, err := paginate(numberedComplaints(tt.count), tt.page, tt.pageSize)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got == nil {
+			t.Errorf("%s: got nil, want an empty slice", tt.name)
+		}
+		if !reflect.DeepEqual(ids(got), tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, ids(got), tt.want)
+		}
+	}
+}
+
+func TestPaginateRejectsPageSizeBelowOne(t *testing.T) {
+	for _, pageSize := range []int{0, -5} {
+		if _, err := paginate(numberedComplaints(3), 1, pageSize); err == nil {
+			t.Errorf("paginate with page size %d: no error", pageSize)
+		}
+	}
+}
+
+func TestTotalPages(t *testing.T) {
+	tests := []struct{ count, pageSize, want int }{
+		{0, 20, 0},
+		{1, 20, 1},
+		{20, 20, 1},
+		{21, 20, 2},
+		{98, 20, 5},
+		{7, math.MaxInt, 1},
+	}
+	for _, tt := range tests {
+		if got := totalPages(tt.count, tt.pageSize); got != tt.want {
+			t.Errorf("totalPages(%d, %d) = %d, want %d", tt.count, tt.pageSize, got, tt.want)
+		}
+	}
+}
+
+func TestPageSizeIsClampedToMaximum(t *testing.T) {
+	for _, name := range []string{"page_size", "pageSize"} {
+		opts, err := parseComplaintListOptions(url.Values{name: {"5000"}})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if opts.PageSize != maxPageSize {
+			t.Errorf("%s=5000 gave page size %d, want %d", name, opts.PageSize, maxPageSize)
+		}
+	}
+
+	page, err := listComplaints(numberedComplaints(150), mustListOptions(t, "page_size=5000&page=2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Complaints) != 50 || page.PageSize != maxPageSize || page.TotalPages != 2 {
+		t.Errorf("page 2 of 150 at the maximum size: %d complaints, page_size %d, total_pages %d, want 50, %d, 2",
+			len(page.Complaints), page.PageSize, page.TotalPages, maxPageSize)
+	}
+}
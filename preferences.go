@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// preferenceKeys is the allowlist of keys accepted in User.Preferences.
+var preferenceKeys = map[string]bool{
+	"notifyOnResolve": true,
+	"notifyOnComment": true,
+	"timezone":        true,
+	"locale":          true,
+	"theme":           true,
+}
+
+// ValidPreferenceKey reports whether key may be stored in User.Preferences.
+func ValidPreferenceKey(key string) bool {
+	return preferenceKeys[key]
+}
+
+// updatePreferencesHandler merges the preferences in the body into the
+// authenticated user's preferences. An empty value removes the key.
+func updatePreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var update map[string]string
+
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var unknown []string
+	for key := range update {
+		if !ValidPreferenceKey(key) {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		writeError(w, fmt.Sprintf("Unknown preference keys: %s", strings.Join(unknown, ", ")), http.StatusBadRequest)
+		return
+	}
+
+	user, exists := findUserByID(authUserID(r))
+	if !exists {
+		writeError(w, "User not found", http.StatusUnauthorized)
+		return
+	}
+
+	// The map is shared with the stored user, so it is copied to leave the
+	// stored preferences untouched if the change cannot be saved.
+	original := user
+	user.Preferences = maps.Clone(user.Preferences)
+	if user.Preferences == nil {
+		user.Preferences = make(map[string]string)
+	}
+	for key, value := range update {
+		if value == "" {
+			delete(user.Preferences, key)
+		} else {
+			user.Preferences[key] = value
+		}
+	}
+	putUser(user)
+
+	if err := saveState(); err != nil {
+		putUser(original)
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(user.Preferences)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestValidPreferenceKey(t *testing.T) {
+	for _, key := range []string{"notifyOnResolve", "notifyOnComment", "timezone", "locale", "theme"} {
+		if !ValidPreferenceKey(key) {
+			t.Errorf("ValidPreferenceKey(%q) = false", key)
+		}
+	}
+	for _, key := range []string{"", "Theme", "isAdmin", "role"} {
+		if ValidPreferenceKey(key) {
+			t.Errorf("ValidPreferenceKey(%q) = true", key)
+		}
+	}
+}
+
+func TestUpdatePreferences(t *testing.T) {
+	resetState(t)
+	registerUser(t, "user-secret")
+	token := login(t, "user-secret")
+
+	update := func(body map[string]string) map[string]string {
+		t.Helper()
+		w := request(t, http.MethodPatch, "/users/me/preferences", token, body)
+		if w.Code != http.StatusOK {
+			t.Fatalf("updating %v: status %d: %s", body, w.Code, w.Body)
+		}
+		var got map[string]string
+		decode(t, w, &got)
+		return got
+	}
+
+	got := update(map[string]string{"theme": "dark", "locale": "en-GB"})
+	if want := map[string]string{"theme": "dark", "locale": "en-GB"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after the first update: %v, want %v", got, want)
+	}
+
+	// Keys not in the body are kept, and an empty value removes a key.
+	got = update(map[string]string{"timezone": "Europe/London", "locale": ""})
+	if want := map[string]string{"theme": "dark", "timezone": "Europe/London"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after the second update: %v, want %v", got, want)
+	}
+
+	var s Store
+	if err := s.Load(dataFile); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if saved := s.Users["user-secret"].Preferences; !reflect.DeepEqual(saved, got) {
+		t.Errorf("saved preferences = %v, want %v", saved, got)
+	}
+}
+
+func TestUpdatePreferencesRejectsUnknownKeys(t *testing.T) {
+	resetState(t)
+	registerUser(t, "user-secret")
+	token := login(t, "user-secret")
+
+	w := request(t, http.MethodPatch, "/users/me/preferences", token, map[string]string{
+		"theme":   "dark",
+		"role":    "admin",
+		"isAdmin": "true",
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	var body map[string]string
+	decode(t, w, &body)
+	if want := "Unknown preference keys: isAdmin, role"; body["error"] != want {
+		t.Errorf("error %q, want %q", body["error"], want)
+	}
+
+	// Nothing is applied when any key is unknown.
+	mu.Lock()
+	prefs := users["user-secret"].Preferences
+	mu.Unlock()
+	if len(prefs) != 0 {
+		t.Errorf("preferences = %v, want none", prefs)
+	}
+}
+
+func TestUpdatePreferencesRequiresToken(t *testing.T) {
+	resetState(t)
+	registerUser(t, "user-secret")
+
+	tests := []struct {
+		name, token string
+		body        interface{}
+	}{
+		{"no token", "", map[string]string{"theme": "dark"}},
+		{"unknown token", "not-a-token", map[string]string{"theme": "dark"}},
+		{"secret code in the body", "", map[string]string{"secretCode": "user-secret", "theme": "dark"}},
+	}
+
+	for _, tt := range tests {
+		if w := request(t, http.MethodPatch, "/users/me/preferences", tt.token, tt.body); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, http.StatusUnauthorized)
+		}
+	}
+}
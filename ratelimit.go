@@ -0,0 +1,334 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// limiter is the rate limit for one key.
+type limiter interface {
+	// allow records a request at now if it is within the limit. Otherwise
+	// it reports when the next request will be allowed.
+	allow(now time.Time) (bool, time.Time)
+	// usage returns the requests counted against the limit at now and
+	// when that count drops back to zero. A limiter with no usage behaves
+	// like a new one.
+	usage(now time.Time) (int, time.Time)
+}
+
+// rateLimiter counts requests in a fixed window.
+type rateLimiter struct {
+	limit   int
+	window  time.Duration
+	count   int
+	resetAt time.Time
+}
+
+// allow records a request at now if it is within the limit, and returns
+// when the current window resets. Rejected requests are not counted.
+func (l *rateLimiter) allow(now time.Time) (bool, time.Time) {
+	if !now.Before(l.resetAt) {
+		l.count = 0
+		l.resetAt = now.Add(l.window)
+	}
+	if l.count >= l.limit {
+		return false, l.resetAt
+	}
+	l.count++
+	return true, l.resetAt
+}
+
+func (l *rateLimiter) usage(now time.Time) (int, time.Time) {
+	if l.count == 0 || !now.Before(l.resetAt) {
+		return 0, time.Time{}
+	}
+	return l.count, l.resetAt
+}
+
+// rateLimitMu guards the rate limiter maps. It is separate from mu and only
+// held while the maps are read or changed, so rate limiting never waits on
+// handlers.
+var rateLimitMu sync.Mutex
+
+// userComplaintRateLimit limits complaint submissions per user ID.
+var userComplaintRateLimit = make(map[string]limiter)
+
+// ipAuthRateLimit limits login and registration attempts per client IP.
+var ipAuthRateLimit = make(map[string]limiter)
+
+// rateLimitTables holds every rate limiter map by the limit type reported
+// for it by the admin endpoints.
+var rateLimitTables = map[string]map[string]limiter{
+	"user_complaints": userComplaintRateLimit,
+	"ip_auth":         ipAuthRateLimit,
+}
+
+// limiterEvictionInterval is how often idle limiters are dropped.
+const limiterEvictionInterval = 5 * time.Minute
+
+// rateLimitEntry describes the current state of one rate limiter. For a
+// token bucket the window is the time until the bucket is full again.
+type rateLimitEntry struct {
+	Key              string    `json:"key"`
+	RequestsInWindow int       `json:"requestsInWindow"`
+	WindowResetAt    time.Time `json:"windowResetAt"`
+	LimitType        string    `json:"limitType"`
+}
+
+// allowComplaint reports whether userID may submit another complaint, and
+// when its current window resets.
+func allowComplaint(userID string) (bool, time.Time) {
+	rateLimitMu.Lock()
+	defer rateLimitMu.Unlock()
+
+	l, exists := userComplaintRateLimit[userID]
+	if !exists {
+		l = &rateLimiter{
+			limit:  config.ComplaintsPerUserPerHour,
+			window: time.Hour,
+		}
+		userComplaintRateLimit[userID] = l
+	}
+
+	return l.allow(time.Now())
+}
+
+// tokenBucket allows bursts of up to burst requests, refilled at rate
+// tokens per second.
+type tokenBucket struct {
+	rate     float64
+	burst    float64
+	tokens   float64
+	lastSeen time.Time
+}
+
+// allow takes a token at now if one is available. Otherwise it returns when
+// the next token will be.
+func (b *tokenBucket) allow(now time.Time) (bool, time.Time) {
+	b.tokens = b.available(now)
+	b.lastSeen = now
+	if b.tokens < 1 {
+		return false, now.Add(b.refillTime(1 - b.tokens))
+	}
+	b.tokens--
+	return true, now
+}
+
+// usage counts every token taken and not yet refilled as a request.
+func (b *tokenBucket) usage(now time.Time) (int, time.Time) {
+	used := b.burst - b.available(now)
+	if used <= 0 {
+		return 0, time.Time{}
+	}
+	return int(math.Ceil(used)), now.Add(b.refillTime(used))
+}
+
+// available returns the tokens in the bucket at now.
+func (b *tokenBucket) available(now time.Time) float64 {
+	return math.Min(b.burst, b.tokens+now.Sub(b.lastSeen).Seconds()*b.rate)
+}
+
+// refillTime returns how long it takes to refill tokens.
+func (b *tokenBucket) refillTime(tokens float64) time.Duration {
+	return time.Duration(tokens / b.rate * float64(time.Second))
+}
+
+// clientIP returns the address the request came from. X-Forwarded-For is
+// only believed when the request arrives from one of config.TrustedProxies,
+// and then the client is the right-most hop that is not a trusted proxy:
+// anything to the left of it was sent by the client and may be forged.
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !trustedProxy(ip) {
+		return ip
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		if hops[i] == "" {
+			continue
+		}
+		ip = hops[i]
+		if !trustedProxy(ip) {
+			break
+		}
+	}
+	return ip
+}
+
+// trustedProxy reports whether addr is in config.TrustedProxies.
+func trustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range config.TrustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// limitByIP rejects requests from a client IP that exceeds
+// config.AuthRequestsPerSecond, allowing bursts of config.AuthRequestBurst.
+func limitByIP(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ip := clientIP(r)
+
+		rateLimitMu.Lock()
+		now := time.Now()
+		l, exists := ipAuthRateLimit[ip]
+		if !exists {
+			l = &tokenBucket{
+				rate:     config.AuthRequestsPerSecond,
+				burst:    float64(config.AuthRequestBurst),
+				tokens:   float64(config.AuthRequestBurst),
+				lastSeen: now,
+			}
+			ipAuthRateLimit[ip] = l
+		}
+		ok, retryAt := l.allow(now)
+		rateLimitMu.Unlock()
+
+		if !ok {
+			seconds := int(math.Ceil(retryAt.Sub(now).Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+			writeError(w, "Rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// evictIdleLimiters drops limiters with no usage every interval until done
+// is closed. They would behave no differently from new ones, and keeping
+// them lets the maps grow with every client ever seen.
+func evictIdleLimiters(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			rateLimitMu.Lock()
+			for _, limiters := range rateLimitTables {
+				for key, l := range limiters {
+					if requests, _ := l.usage(now); requests == 0 {
+						delete(limiters, key)
+					}
+				}
+			}
+			rateLimitMu.Unlock()
+		}
+	}
+}
+
+func writeRateLimited(w http.ResponseWriter, resetAt time.Time) {
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(resetAt.Unix(), 10))
+	writeError(w, "Rate limit exceeded", http.StatusTooManyRequests)
+}
+
+// adminRateLimitsHandler lists every rate limiter with requests in its
+// current window, busiest first.
+func adminRateLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := authorizeAdmin(w, r, legacySecretCode(r)); !ok {
+		return
+	}
+
+	rateLimitMu.Lock()
+	now := time.Now()
+	entries := []rateLimitEntry{}
+	for limitType, limiters := range rateLimitTables {
+		for key, l := range limiters {
+			requests, resetAt := l.usage(now)
+			if requests == 0 {
+				continue
+			}
+			entries = append(entries, rateLimitEntry{
+				Key:              key,
+				RequestsInWindow: requests,
+				WindowResetAt:    resetAt,
+				LimitType:        limitType,
+			})
+		}
+	}
+	rateLimitMu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].RequestsInWindow != entries[j].RequestsInWindow {
+			return entries[i].RequestsInWindow > entries[j].RequestsInWindow
+		}
+		if entries[i].LimitType != entries[j].LimitType {
+			return entries[i].LimitType < entries[j].LimitType
+		}
+		return entries[i].Key < entries[j].Key
+	})
+
+	json.NewEncoder(w).Encode(entries)
+}
+
+// adminResetRateLimitHandler clears every rate limiter stored under the key
+// in the path.
+func adminResetRateLimitHandler(w http.ResponseWriter, r *http.Request) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	admin, ok := authorizeAdmin(w, r, legacySecretCode(r))
+	if !ok {
+		return
+	}
+
+	// The removed limiters are kept so a failed save can put them back.
+	key := r.PathValue("key")
+	removed := make(map[string]limiter)
+	rateLimitMu.Lock()
+	for limitType, limiters := range rateLimitTables {
+		if l, exists := limiters[key]; exists {
+			removed[limitType] = l
+			delete(limiters, key)
+		}
+	}
+	rateLimitMu.Unlock()
+
+	if len(removed) == 0 {
+		writeError(w, "Rate limit not found", http.StatusNotFound)
+		return
+	}
+
+	recordAudit(admin.ID, "reset_rate_limit", "", key)
+
+	if err := saveState(); err != nil {
+		dropLastAudit()
+		rateLimitMu.Lock()
+		for limitType, l := range removed {
+			rateLimitTables[limitType][key] = l
+		}
+		rateLimitMu.Unlock()
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
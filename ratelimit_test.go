@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// loginFrom attempts a login with secretCode from remoteAddr, with
+// forwardedFor as the X-Forwarded-For header if it is not empty.
+func loginFrom(t *testing.T, remoteAddr, forwardedFor, secretCode string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	body, err := json.Marshal(map[string]string{"secretCode": secretCode})
+	if err != nil {
+		t.Fatalf("encoding request body: %v", err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader(body))
+	r.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		r.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	w := httptest.NewRecorder()
+	NewRouter().ServeHTTP(w, r)
+	return w
+}
+
+func TestAuthIsRateLimitedPerIP(t *testing.T) {
+	resetState(t)
+	// Slow enough that no token is refilled while the test runs.
+	config.AuthRequestsPerSecond = 0.01
+	config.AuthRequestBurst = 3
+
+	for i := 0; i < config.AuthRequestBurst; i++ {
+		if w := loginFrom(t, "10.0.0.1:1000", "", "wrong-secret"); w.Code != http.StatusNotFound {
+			t.Fatalf("attempt %d: status %d, want %d", i+1, w.Code, http.StatusNotFound)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		// The port changes with every connection, so it is not part of the key.
+		w := loginFrom(t, "10.0.0.1:"+strconv.Itoa(2000+i), "", "wrong-secret")
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("attempt past the burst: status %d, want %d", w.Code, http.StatusTooManyRequests)
+		}
+		retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+		if err != nil || retryAfter < 1 || retryAfter > 100 {
+			t.Errorf("Retry-After %q, want the seconds until the next token", w.Header().Get("Retry-After"))
+		}
+	}
+
+	// Registration draws from the same bucket.
+	r := httptest.NewRequest(http.MethodPost, "/users", bytes.NewReader([]byte(`{}`)))
+	r.RemoteAddr = "10.0.0.1:3000"
+	w := httptest.NewRecorder()
+	NewRouter().ServeHTTP(w, r)
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("registering from the limited IP: status %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+
+	if w := loginFrom(t, "10.0.0.2:1000", "", "wrong-secret"); w.Code != http.StatusNotFound {
+		t.Errorf("another IP: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestForwardedForIsIgnoredFromUntrustedClients(t *testing.T) {
+	resetState(t)
+	config.AuthRequestsPerSecond = 0.01
+	config.AuthRequestBurst = 2
+
+	// A client that makes up a new X-Forwarded-For for every request still
+	// runs out of tokens.
+	for i := 0; i < config.AuthRequestBurst; i++ {
+		loginFrom(t, "10.0.0.1:1000", "203.0.113."+strconv.Itoa(i), "wrong-secret")
+	}
+	if w := loginFrom(t, "10.0.0.1:1000", "203.0.113.99", "wrong-secret"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("status %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+
+	proxies, err := parseNetworks("10.0.0.0/8, 192.168.1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.TrustedProxies = proxies
+
+	tests := []struct {
+		remoteAddr   string
+		forwardedFor []string
+		want         string
+	}{
+		{"203.0.113.5:1000", nil, "203.0.113.5"},
+		{"203.0.113.5:1000", []string{"198.51.100.7"}, "203.0.113.5"},
+		{"10.1.2.3:1000", nil, "10.1.2.3"},
+		{"10.1.2.3:1000", []string{"198.51.100.7"}, "198.51.100.7"},
+		{"192.168.1.1:1000", []string{"198.51.100.7"}, "198.51.100.7"},
+		{"192.168.1.2:1000", []string{"198.51.100.7"}, "192.168.1.2"},
+		// Hops to the left of the first untrusted one were sent by the
+		// client and may be forged.
+		{"10.1.2.3:1000", []string{"1.1.1.1, 198.51.100.7, 10.4.5.6"}, "198.51.100.7"},
+		{"10.1.2.3:1000", []string{"1.1.1.1", "198.51.100.7", "10.4.5.6"}, "198.51.100.7"},
+		{"10.1.2.3:1000", []string{"198.51.100.7, "}, "198.51.100.7"},
+		{"10.1.2.3:1000", []string{"10.4.5.6"}, "10.4.5.6"},
+		{"[2001:db8::1]:1000", []string{"198.51.100.7"}, "2001:db8::1"},
+		{"no-port", nil, "no-port"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/login", nil)
+		r.RemoteAddr = tt.remoteAddr
+		for _, value := range tt.forwardedFor {
+			r.Header.Add("X-Forwarded-For", value)
+		}
+		if got := clientIP(r); got != tt.want {
+			t.Errorf("clientIP(%s, %q) = %q, want %q", tt.remoteAddr, tt.forwardedFor, got, tt.want)
+		}
+	}
+}
+
+func TestParseNetworksRejectsBadEntries(t *testing.T) {
+	for _, value := range []string{"10.0.0.0/33", "not-an-ip", "10.0.0.1, nope"} {
+		if _, err := parseNetworks(value); err == nil {
+			t.Errorf("parseNetworks(%q) succeeded", value)
+		}
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := &tokenBucket{rate: 2, burst: 2, tokens: 2, lastSeen: start}
+
+	if requests, _ := b.usage(start); requests != 0 {
+		t.Errorf("usage of a full bucket = %d, want 0", requests)
+	}
+	for i := 0; i < 2; i++ {
+		if ok, _ := b.allow(start); !ok {
+			t.Fatalf("request %d was rejected", i+1)
+		}
+	}
+	ok, retryAt := b.allow(start)
+	if ok || !retryAt.Equal(start.Add(500*time.Millisecond)) {
+		t.Errorf("allow on an empty bucket = %v, %v, want false, %v", ok, retryAt, start.Add(500*time.Millisecond))
+	}
+	if requests, resetAt := b.usage(start); requests != 2 || !resetAt.Equal(start.Add(time.Second)) {
+		t.Errorf("usage = %d, %v, want 2, %v", requests, resetAt, start.Add(time.Second))
+	}
+
+	if ok, _ := b.allow(start.Add(500 * time.Millisecond)); !ok {
+		t.Error("request after a refill was rejected")
+	}
+	if requests, _ := b.usage(start.Add(time.Hour)); requests != 0 {
+		t.Errorf("usage after an hour = %d, want 0", requests)
+	}
+}
+
+func TestRateLimiterWindow(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := &rateLimiter{limit: 2, window: time.Hour}
+
+	for i := 0; i < 2; i++ {
+		if ok, resetAt := l.allow(start.Add(time.Duration(i) * time.Minute)); !ok || !resetAt.Equal(start.Add(time.Hour)) {
+			t.Fatalf("request %d = %v, %v", i+1, ok, resetAt)
+		}
+	}
+	if ok, _ := l.allow(start.Add(time.Minute)); ok {
+		t.Error("request over the limit was allowed")
+	}
+	if requests, resetAt := l.usage(start.Add(time.Minute)); requests != 2 || !resetAt.Equal(start.Add(time.Hour)) {
+		t.Errorf("usage = %d, %v, want 2, %v", requests, resetAt, start.Add(time.Hour))
+	}
+	if ok, resetAt := l.allow(start.Add(time.Hour)); !ok || !resetAt.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("request in the next window = %v, %v", ok, resetAt)
+	}
+}
+
+func TestComplaintsAreRateLimitedPerUser(t *testing.T) {
+	resetState(t)
+	config.ComplaintsPerUserPerHour = 2
+
+	registerUser(t, "first-secret")
+	first := login(t, "first-secret")
+	registerUser(t, "second-secret")
+	second := login(t, "second-secret")
+
+	submitComplaint(t, first, "One", 1)
+	submitComplaint(t, first, "Two", 1)
+
+	w := request(t, http.MethodPost, "/complaints", first, map[string]interface{}{"title": "Three", "severity": 1})
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("third complaint: status %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	resetAt, err := strconv.ParseInt(w.Header().Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil || resetAt <= time.Now().Unix() {
+		t.Errorf("X-RateLimit-Reset %q, want a time in the next hour", w.Header().Get("X-RateLimit-Reset"))
+	}
+
+	mu.Lock()
+	count := len(complaints)
+	mu.Unlock()
+	if count != 2 {
+		t.Errorf("%d complaints stored, want 2", count)
+	}
+
+	submitComplaint(t, second, "Other user", 1)
+}
+
+func TestLoadConfig(t *testing.T) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+
+	t.Setenv("COMPLAINTS_PER_USER_PER_HOUR", "25")
+	if err := loadConfig(); err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if config.ComplaintsPerUserPerHour != 25 {
+		t.Errorf("ComplaintsPerUserPerHour = %d, want 25", config.ComplaintsPerUserPerHour)
+	}
+
+	for _, value := range []string{"0", "-1", "ten"} {
+		t.Setenv("COMPLAINTS_PER_USER_PER_HOUR", value)
+		if err := loadConfig(); err == nil {
+			t.Errorf("loadConfig accepted %q", value)
+		}
+	}
+}
+
+func TestAdminRateLimitEndpoints(t *testing.T) {
+	resetState(t)
+	config.ComplaintsPerUserPerHour = 2
+
+	_, adminToken := addAdmin(t, "admin-secret")
+	busyID := registerUser(t, "busy-secret")
+	busy := login(t, "busy-secret")
+	quietID := registerUser(t, "quiet-secret")
+	submitComplaint(t, busy, "One", 1)
+	submitComplaint(t, busy, "Two", 1)
+	submitComplaint(t, login(t, "quiet-secret"), "Three", 1)
+
+	if w := request(t, http.MethodGet, "/admin/ratelimits", "", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("listing without a token: status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	w := request(t, http.MethodGet, "/admin/ratelimits", adminToken, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("listing: status %d: %s", w.Code, w.Body)
+	}
+	var all, entries []rateLimitEntry
+	decode(t, w, &all)
+	// Registering and logging in above drew on the per-IP limit as well.
+	for _, entry := range all {
+		if entry.LimitType == "user_complaints" {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) != 2 || entries[0].Key != busyID || entries[0].RequestsInWindow != 2 || entries[1].Key != quietID || entries[1].RequestsInWindow != 1 {
+		t.Fatalf("entries = %+v, want %s with 2 requests, then %s with 1", entries, busyID, quietID)
+	}
+	if entries[0].LimitType != "user_complaints" || !entries[0].WindowResetAt.After(time.Now()) {
+		t.Errorf("entry = %+v, want a user_complaints limit resetting in the future", entries[0])
+	}
+
+	if w := request(t, http.MethodPost, "/complaints", busy, map[string]interface{}{"title": "Four", "severity": 1}); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("over the limit: status %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if w := request(t, http.MethodDelete, "/admin/ratelimits/"+busyID, adminToken, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("resetting: status %d: %s", w.Code, w.Body)
+	}
+	submitComplaint(t, busy, "Four", 1)
+
+	if w := request(t, http.MethodDelete, "/admin/ratelimits/999", adminToken, nil); w.Code != http.StatusNotFound {
+		t.Errorf("resetting an unknown key: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestAdminRateLimitsIncludeIPAuth(t *testing.T) {
+	resetState(t)
+	adminID, adminToken := addAdmin(t, "admin-secret")
+	userID := registerUser(t, "user-secret")
+	submitComplaint(t, login(t, "user-secret"), "Noise", 1)
+
+	config.AuthRequestsPerSecond = 0.01
+	config.AuthRequestBurst = 2
+	for i := 0; i < 3; i++ {
+		loginFrom(t, "10.0.0.1:1000", "", "wrong-secret")
+	}
+
+	var entries []rateLimitEntry
+	decode(t, request(t, http.MethodGet, "/admin/ratelimits", adminToken, nil), &entries)
+	found := map[string]int{}
+	for _, entry := range entries {
+		found[entry.LimitType+" "+entry.Key] = entry.RequestsInWindow
+	}
+	if found["ip_auth 10.0.0.1"] != 2 {
+		t.Errorf("ip_auth usage for 10.0.0.1 = %d, want 2: %+v", found["ip_auth 10.0.0.1"], entries)
+	}
+	if found["user_complaints "+userID] != 1 {
+		t.Errorf("user_complaints usage for %s = %d, want 1: %+v", userID, found["user_complaints "+userID], entries)
+	}
+
+	if w := request(t, http.MethodDelete, "/admin/ratelimits/10.0.0.1", adminToken, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("resetting: status %d: %s", w.Code, w.Body)
+	}
+	if w := loginFrom(t, "10.0.0.1:1000", "", "wrong-secret"); w.Code != http.StatusNotFound {
+		t.Errorf("after the reset: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if w := request(t, http.MethodDelete, "/admin/ratelimits/10.9.9.9", adminToken, nil); w.Code != http.StatusNotFound {
+		t.Errorf("resetting an unknown key: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	mu.Lock()
+	last := auditLog[len(auditLog)-1]
+	mu.Unlock()
+	if last.Action != "reset_rate_limit" || last.Detail != "10.0.0.1" || last.AdminID != adminID {
+		t.Errorf("audit entry = %+v", last)
+	}
+}
+
+func TestEvictIdleLimiters(t *testing.T) {
+	resetState(t)
+
+	now := time.Now()
+	rateLimitMu.Lock()
+	ipAuthRateLimit["idle"] = &tokenBucket{rate: 1, burst: 1, tokens: 1, lastSeen: now}
+	ipAuthRateLimit["busy"] = &tokenBucket{rate: 0.001, burst: 1, tokens: 0, lastSeen: now}
+	userComplaintRateLimit["idle"] = &rateLimiter{limit: 1, window: time.Hour}
+	rateLimitMu.Unlock()
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		evictIdleLimiters(time.Millisecond, done)
+		close(stopped)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rateLimitMu.Lock()
+		_, idleIP := ipAuthRateLimit["idle"]
+		_, idleUser := userComplaintRateLimit["idle"]
+		_, busy := ipAuthRateLimit["busy"]
+		rateLimitMu.Unlock()
+
+		if !busy {
+			t.Fatal("a limiter in use was evicted")
+		}
+		if !idleIP && !idleUser {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("idle limiters were not evicted")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(done)
+	<-stopped
+}
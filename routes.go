@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// NewRouter returns the handler serving every API route.
+func NewRouter() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/", notFoundHandler)
+	mux.HandleFunc("/users", methodOnly(http.MethodPost, limitByIP(registerHandler)))
+	mux.HandleFunc("/users/me/preferences", methodOnly(http.MethodPatch, requireAuth(updatePreferencesHandler)))
+	mux.HandleFunc("/login", methodOnly(http.MethodPost, limitByIP(loginHandler)))
+	mux.Handle("/complaints", methodHandlers{
+		http.MethodGet:  requireAuth(getAllComplaintsForUserHandler),
+		http.MethodPost: requireAuth(submitComplaintHandler),
+	})
+	mux.Handle("/complaints/{id}", methodHandlers{
+		http.MethodGet:    requireAuth(viewComplaintHandler),
+		http.MethodPatch:  requireAuth(updateComplaintHandler),
+		http.MethodDelete: deleteComplaintHandler,
+	})
+	mux.HandleFunc("/complaints/{id}/resolve", methodOnly(http.MethodPatch, resolveComplaintHandler))
+	mux.HandleFunc("/complaints/{id}/status", methodOnly(http.MethodPatch, updateComplaintStatusHandler))
+	mux.HandleFunc("/registerAdmin", methodOnly(http.MethodPost, registerAdminHandler))
+	mux.HandleFunc("/admin/users/{id}/role", methodOnly(http.MethodPatch, updateUserRoleHandler))
+	mux.HandleFunc("/admin/complaints", methodOnly(http.MethodGet, getAllComplaintsForAdminHandler))
+	mux.HandleFunc("/admin/complaints/byResolutionNote", methodOnly(http.MethodGet, adminComplaintsByResolutionNoteHandler))
+	mux.HandleFunc("/admin/complaints/export", methodOnly(http.MethodGet, exportComplaintsHandler))
+	mux.HandleFunc("/admin/complaints/import", methodOnly(http.MethodPost, importComplaintsHandler))
+	mux.HandleFunc("/auditLog", methodOnly(http.MethodGet, auditLogHandler))
+	mux.HandleFunc("/admin/ratelimits", methodOnly(http.MethodGet, adminRateLimitsHandler))
+	mux.HandleFunc("/admin/ratelimits/{key}", methodOnly(http.MethodDelete, adminResetRateLimitHandler))
+	mux.HandleFunc("/metrics", methodOnly(http.MethodGet, metricsHandler))
+
+	// Deprecated endpoints from before the RESTful routes, kept as aliases
+	// for one release. The GET ones take id and secretCode as query
+	// parameters, the others in the JSON body.
+	mux.HandleFunc("/register", methodOnly(http.MethodPost, limitByIP(registerHandler)))
+	mux.HandleFunc("/submitComplaint", methodOnly(http.MethodPost, requireAuth(submitComplaintHandler)))
+	mux.HandleFunc("/getAllComplaintsForUser", methodOnly(http.MethodGet, requireAuth(getAllComplaintsForUserHandler)))
+	mux.HandleFunc("/getAllComplaintsForAdmin", methodOnly(http.MethodGet, legacyRoute(getAllComplaintsForAdminHandler)))
+	mux.HandleFunc("/viewComplaint", methodOnly(http.MethodGet, legacyRoute(requireAuth(viewComplaintHandler))))
+	mux.HandleFunc("/resolveComplaint", methodOnly(http.MethodPost, legacyRoute(resolveComplaintHandler)))
+	mux.HandleFunc("/updateComplaint", methodOnly(http.MethodPatch, legacyRoute(requireAuthOrSecretCode(updateComplaintHandler))))
+	mux.HandleFunc("/deleteComplaint", methodOnly(http.MethodDelete, legacyRoute(deleteComplaintHandler)))
+	mux.HandleFunc("/updateComplaintStatus", methodOnly(http.MethodPatch, legacyRoute(updateComplaintStatusHandler)))
+
+	return logRequests(mux)
+}
+
+// methodHandlers dispatches a request to the handler registered for its
+// HTTP method and answers 405 for any other method.
+type methodHandlers map[string]http.HandlerFunc
+
+func (m methodHandlers) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if handler, ok := m[r.Method]; ok {
+		handler(w, r)
+		return
+	}
+
+	allowed := make([]string, 0, len(m))
+	for method := range m {
+		allowed = append(allowed, method)
+	}
+	sort.Strings(allowed)
+
+	writeMethodNotAllowed(w, allowed...)
+}
+
+// methodOnly answers 405 for any request whose method is not method.
+func methodOnly(method string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			writeMethodNotAllowed(w, method)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func writeMethodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+}
+
+func notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	writeError(w, "Not found", http.StatusNotFound)
+}
+
+// legacyRoute adapts a handler to the old endpoints, which take the
+// complaint ID and the secret code as parameters rather than in the path and
+// a bearer token. GET requests carry them in the id and secretCode query
+// parameters, other methods in the "id" and "secretCode" fields of the JSON
+// body. The ID becomes the path value the handler expects and the secret
+// code is made available through legacySecretCode. The body is left
+// readable for next.
+func legacyRoute(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			query := r.URL.Query()
+			r.SetPathValue("id", query.Get("id"))
+			ctx := context.WithValue(r.Context(), secretCodeKey, query.Get("secretCode"))
+			next(w, r.WithContext(ctx))
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var fields struct {
+			ID         string `json:"id"`
+			SecretCode string `json:"secretCode"`
+		}
+
+		if err := json.Unmarshal(body, &fields); err != nil {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		r.SetPathValue("id", fields.ID)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		ctx := context.WithValue(r.Context(), secretCodeKey, fields.SecretCode)
+
+		next(w, r.WithContext(ctx))
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRESTRoutes(t *testing.T) {
+	resetState(t)
+	config.AuthRequestBurst = 1000
+	_, adminToken := addAdmin(t, "admin-secret")
+	userID := registerUser(t, "user-secret")
+	token := login(t, "user-secret")
+	id := submitComplaint(t, token, "Noise", 2)
+
+	tests := []struct {
+		method, target, token string
+		body                  interface{}
+		want                  int
+	}{
+		{http.MethodPost, "/users", "", map[string]string{"secretCode": "other-secret", "name": "Other", "email": "other@example.com"}, http.StatusOK},
+		{http.MethodPost, "/login", "", map[string]string{"secretCode": "user-secret"}, http.StatusOK},
+		{http.MethodGet, "/complaints", token, nil, http.StatusOK},
+		{http.MethodPost, "/complaints", token, map[string]interface{}{"title": "Leak", "summary": "s", "severity": 1}, http.StatusCreated},
+		{http.MethodGet, "/complaints/" + id, token, nil, http.StatusOK},
+		{http.MethodPatch, "/complaints/" + id + "/resolve", adminToken, nil, http.StatusNoContent},
+		{http.MethodPost, "/registerAdmin", adminToken, map[string]string{"secretCode": "second-admin", "name": "Second", "email": "second@example.com"}, http.StatusOK},
+		{http.MethodGet, "/admin/complaints", adminToken, nil, http.StatusOK},
+		{http.MethodPatch, "/admin/users/" + userID + "/role", adminToken, map[string]string{"role": RoleUser}, http.StatusOK},
+
+		{http.MethodGet, "/users", "", nil, http.StatusMethodNotAllowed},
+		{http.MethodGet, "/login", "", nil, http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/complaints", token, nil, http.StatusMethodNotAllowed},
+		{http.MethodPut, "/complaints/" + id, token, nil, http.StatusMethodNotAllowed},
+		{http.MethodPost, "/complaints/" + id + "/resolve", "", nil, http.StatusMethodNotAllowed},
+		{http.MethodGet, "/registerAdmin", "", nil, http.StatusMethodNotAllowed},
+		{http.MethodPost, "/admin/complaints", "", nil, http.StatusMethodNotAllowed},
+
+		{http.MethodGet, "/complaints/999", token, nil, http.StatusNotFound},
+		{http.MethodGet, "/no/such/route", "", nil, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		w := request(t, tt.method, tt.target, tt.token, tt.body)
+		if w.Code != tt.want {
+			t.Errorf("%s %s: status %d, want %d: %s", tt.method, tt.target, w.Code, tt.want, w.Body)
+		}
+		if got := w.Header().Get("Content-Type"); w.Code >= 400 && got != "application/json" {
+			t.Errorf("%s %s: error Content-Type %q, want application/json", tt.method, tt.target, got)
+		}
+	}
+
+	mu.Lock()
+	resolved := complaints[id].Status == StatusResolved
+	mu.Unlock()
+	if !resolved {
+		t.Error("complaint was not resolved")
+	}
+}
+
+func TestMethodNotAllowedListsAllowedMethods(t *testing.T) {
+	resetState(t)
+
+	tests := []struct {
+		method, target, allow string
+	}{
+		{http.MethodPut, "/complaints", "GET, POST"},
+		{http.MethodPost, "/complaints/1", "DELETE, GET, PATCH"},
+		{http.MethodGet, "/complaints/1/resolve", "PATCH"},
+		{http.MethodDelete, "/users", "POST"},
+	}
+
+	for _, tt := range tests {
+		w := request(t, tt.method, tt.target, "", nil)
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.target, w.Code, http.StatusMethodNotAllowed)
+		}
+		if got := w.Header().Get("Allow"); got != tt.allow {
+			t.Errorf("%s %s: Allow %q, want %q", tt.method, tt.target, got, tt.allow)
+		}
+	}
+}
+
+func TestRESTRoutesIgnoreSecretCodes(t *testing.T) {
+	resetState(t)
+	addAdmin(t, "admin-secret")
+	registerUser(t, "user-secret")
+	id := submitComplaint(t, login(t, "user-secret"), "Noise", 2)
+
+	// Secret codes are only credentials on the deprecated aliases; the REST
+	// routes take bearer tokens alone.
+	tests := []struct {
+		method, target string
+		body           interface{}
+	}{
+		{http.MethodGet, "/admin/complaints?secretCode=admin-secret", nil},
+		{http.MethodPatch, "/complaints/" + id + "/resolve?secretCode=admin-secret", nil},
+		{http.MethodPatch, "/complaints/" + id + "/resolve", map[string]string{"secretCode": "admin-secret"}},
+		{http.MethodPost, "/registerAdmin", map[string]string{"adminSecretCode": "admin-secret", "secretCode": "second-admin"}},
+		{http.MethodPost, "/registerAdmin?secretCode=admin-secret", map[string]string{"secretCode": "second-admin"}},
+	}
+
+	for _, tt := range tests {
+		if w := request(t, tt.method, tt.target, "", tt.body); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s: status %d, want %d: %s", tt.method, tt.target, w.Code, http.StatusUnauthorized, w.Body)
+		}
+	}
+
+	mu.Lock()
+	resolved := complaints[id].Status == StatusResolved
+	mu.Unlock()
+	if resolved {
+		t.Error("complaint was resolved with a secret code")
+	}
+}
+
+func TestLegacyRoutes(t *testing.T) {
+	resetState(t)
+	addAdmin(t, "admin-secret")
+
+	w := request(t, http.MethodPost, "/register", "", map[string]string{"secretCode": "user-secret", "name": "User", "email": "user@example.com"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("registering: status %d: %s", w.Code, w.Body)
+	}
+	token := login(t, "user-secret")
+
+	w = request(t, http.MethodPost, "/submitComplaint", token, map[string]interface{}{"title": "Noise", "severity": 2})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("submitting: status %d: %s", w.Code, w.Body)
+	}
+	id := "1"
+
+	tests := []struct {
+		method, target, token string
+		body                  interface{}
+		want                  int
+	}{
+		{http.MethodGet, "/getAllComplaintsForUser", token, nil, http.StatusOK},
+		{http.MethodGet, "/viewComplaint?id=" + id, token, nil, http.StatusOK},
+		{http.MethodGet, "/getAllComplaintsForAdmin?secretCode=admin-secret", "", nil, http.StatusOK},
+		{http.MethodGet, "/getAllComplaintsForAdmin?secretCode=guess", "", nil, http.StatusUnauthorized},
+		{http.MethodPatch, "/updateComplaintStatus", "", map[string]string{"id": id, "secretCode": "admin-secret", "status": "in_progress"}, http.StatusOK},
+		{http.MethodPatch, "/updateComplaintStatus", "", map[string]string{"id": id, "secretCode": "user-secret", "status": "open"}, http.StatusOK},
+		{http.MethodPost, "/resolveComplaint", "", map[string]string{"id": id, "secretCode": "admin-secret"}, http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		if w := request(t, tt.method, tt.target, tt.token, tt.body); w.Code != tt.want {
+			t.Errorf("%s %s: status %d, want %d: %s", tt.method, tt.target, w.Code, tt.want, w.Body)
+		}
+	}
+}
+
+func TestWrongMethodsAreRejectedOnEveryRoute(t *testing.T) {
+	resetState(t)
+	_, adminToken := addAdmin(t, "admin-secret")
+	registerUser(t, "user-secret")
+	id := submitComplaint(t, login(t, "user-secret"), "Noise", 2)
+
+	routes := []struct {
+		target  string
+		allowed []string
+	}{
+		{"/users", []string{http.MethodPost}},
+		{"/users/me/preferences", []string{http.MethodPatch}},
+		{"/login", []string{http.MethodPost}},
+		{"/complaints", []string{http.MethodGet, http.MethodPost}},
+		{"/complaints/" + id, []string{http.MethodDelete, http.MethodGet, http.MethodPatch}},
+		{"/complaints/" + id + "/resolve", []string{http.MethodPatch}},
+		{"/complaints/" + id + "/status", []string{http.MethodPatch}},
+		{"/registerAdmin", []string{http.MethodPost}},
+		{"/admin/users/1/role", []string{http.MethodPatch}},
+		{"/admin/complaints", []string{http.MethodGet}},
+		{"/admin/complaints/byResolutionNote", []string{http.MethodGet}},
+		{"/admin/complaints/export", []string{http.MethodGet}},
+		{"/admin/complaints/import", []string{http.MethodPost}},
+		{"/auditLog", []string{http.MethodGet}},
+		{"/admin/ratelimits", []string{http.MethodGet}},
+		{"/admin/ratelimits/1", []string{http.MethodDelete}},
+		{"/metrics", []string{http.MethodGet}},
+
+		{"/register", []string{http.MethodPost}},
+		{"/submitComplaint", []string{http.MethodPost}},
+		{"/getAllComplaintsForUser", []string{http.MethodGet}},
+		{"/getAllComplaintsForAdmin", []string{http.MethodGet}},
+		{"/viewComplaint", []string{http.MethodGet}},
+		{"/resolveComplaint", []string{http.MethodPost}},
+		{"/updateComplaint", []string{http.MethodPatch}},
+		{"/deleteComplaint", []string{http.MethodDelete}},
+		{"/updateComplaintStatus", []string{http.MethodPatch}},
+	}
+	methods := []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+	for _, route := range routes {
+		allow := strings.Join(route.allowed, ", ")
+		for _, method := range methods {
+			if slices.Contains(route.allowed, method) {
+				continue
+			}
+			// The body would be accepted by the right method, so a 405 shows
+			// the handler never ran.
+			body := map[string]string{"id": id, "secretCode": "admin-secret", "status": "resolved", "title": "Changed"}
+			w := request(t, method, route.target, adminToken, body)
+			if w.Code != http.StatusMethodNotAllowed {
+				t.Errorf("%s %s: status %d, want %d", method, route.target, w.Code, http.StatusMethodNotAllowed)
+			}
+			if got := w.Header().Get("Allow"); got != allow {
+				t.Errorf("%s %s: Allow %q, want %q", method, route.target, got, allow)
+			}
+		}
+	}
+
+	mu.Lock()
+	complaint, exists := complaints[id]
+	mu.Unlock()
+	if !exists || complaint.Status != StatusOpen || complaint.Title != "Noise" {
+		t.Errorf("complaint changed by rejected requests: %+v", complaint)
+	}
+}
+
+func TestGetRoutesIgnoreTheBody(t *testing.T) {
+	resetState(t)
+	registerUser(t, "user-secret")
+	token := login(t, "user-secret")
+	id := submitComplaint(t, token, "Noise", 2)
+
+	for _, target := range []string{"/complaints", "/complaints/" + id, "/getAllComplaintsForUser", "/viewComplaint?id=" + id} {
+		// A body on a GET is ignored, even one naming another complaint.
+		w := request(t, http.MethodGet, target, token, map[string]string{"id": "999", "secretCode": "wrong"})
+		if w.Code != http.StatusOK {
+			t.Errorf("GET %s: status %d, want %d: %s", target, w.Code, http.StatusOK, w.Body)
+		}
+	}
+}
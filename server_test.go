@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// freeAddr returns a local address that nothing is listening on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+func TestServerEndToEnd(t *testing.T) {
+	resetState(t)
+
+	addr := freeAddr(t)
+	server := newServer(addr, NewRouter())
+	done := make(chan error, 1)
+	go func() {
+		done <- run(server)
+	}()
+
+	base := "http://" + addr
+	client := &http.Client{Timeout: 5 * time.Second}
+	post := func(path, token string, body interface{}) *http.Response {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		r, err := http.NewRequest(http.MethodPost, base+path, bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := client.Do(r)
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		return resp
+	}
+
+	// Wait for the server to start listening.
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("server did not start: %v", err)
+		}
+	}
+
+	resp := post("/users", "", map[string]string{"secretCode": "user-secret", "name": "User", "email": "user@example.com"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("registering: status %d", resp.StatusCode)
+	}
+
+	resp = post("/login", "", map[string]string{"secretCode": "user-secret"})
+	var session struct {
+		Token string `json:"token"`
+	}
+	json.NewDecoder(resp.Body).Decode(&session)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || session.Token == "" {
+		t.Fatalf("logging in: status %d", resp.StatusCode)
+	}
+
+	resp = post("/complaints", session.Token, map[string]interface{}{"title": "Noise", "summary": "Loud", "severity": 2})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("submitting: status %d", resp.StatusCode)
+	}
+
+	// run stops on SIGINT. It has caught the signal since before it started
+	// listening, so this does not stop the test binary.
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGINT); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("run: %v", err)
+		}
+	case <-time.After(shutdownTimeout + 5*time.Second):
+		t.Fatal("server did not shut down")
+	}
+
+	if _, err := net.Dial("tcp", addr); err == nil {
+		t.Error("server still accepts connections after shutdown")
+	}
+
+	// The complaint was persisted before the server stopped.
+	var s Store
+	if err := s.Load(dataFile); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Complaints) != 1 {
+		t.Errorf("data file holds %d complaints, want 1", len(s.Complaints))
+	}
+}
+
+func TestRunFailsWhenAddressIsTaken(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	if err := run(newServer(listener.Addr().String(), NewRouter())); err == nil {
+		t.Error("run on a taken address returned nil")
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ComplaintStatus is the stage of a complaint's lifecycle
+type ComplaintStatus string
+
+const (
+	StatusOpen       ComplaintStatus = "open"
+	StatusInProgress ComplaintStatus = "in_progress"
+	StatusResolved   ComplaintStatus = "resolved"
+	StatusRejected   ComplaintStatus = "rejected"
+)
+
+// statusTransitions lists the statuses each status may move to. Resolved and
+// rejected complaints are closed and cannot change status again.
+var statusTransitions = map[ComplaintStatus][]ComplaintStatus{
+	StatusOpen:       {StatusInProgress, StatusResolved, StatusRejected},
+	StatusInProgress: {StatusOpen, StatusResolved, StatusRejected},
+	StatusResolved:   {},
+	StatusRejected:   {},
+}
+
+// valid reports whether s is a known status.
+func (s ComplaintStatus) valid() bool {
+	_, exists := statusTransitions[s]
+	return exists
+}
+
+// closed reports whether s is a terminal status.
+func (s ComplaintStatus) closed() bool {
+	return s == StatusResolved || s == StatusRejected
+}
+
+// canTransition reports whether a complaint may move from one status to
+// another.
+func canTransition(from, to ComplaintStatus) bool {
+	for _, next := range statusTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// complaintJSON has the fields of Complaint without its JSON methods.
+type complaintJSON Complaint
+
+// MarshalJSON adds the resolved flag, derived from the status, for clients
+// written before complaints had a status.
+func (c Complaint) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		complaintJSON
+		Resolved bool `json:"resolved"`
+	}{complaintJSON(c), c.Status == StatusResolved})
+}
+
+// UnmarshalJSON also reads complaints saved before they had a status, which
+// only carry the resolved flag.
+func (c *Complaint) UnmarshalJSON(data []byte) error {
+	var v struct {
+		complaintJSON
+		Resolved bool `json:"resolved"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*c = Complaint(v.complaintJSON)
+
+	if c.Status == "" {
+		c.Status = StatusOpen
+		if v.Resolved {
+			c.Status = StatusResolved
+		}
+	}
+	return nil
+}
+
+// setStatus moves c to status on behalf of changedBy. The transition must
+// already have been checked with canTransition.
+func (c *Complaint) setStatus(status ComplaintStatus, changedBy string, now time.Time) {
+	c.Status = status
+	c.StatusChangedBy = changedBy
+	c.UpdatedAt = now
+	if status == StatusResolved {
+		c.ResolvedAt = &now
+	}
+}
+
+// updateComplaintStatusHandler moves a complaint to the status in the body.
+// Admins may make any legal transition; the owner may only move their
+// complaint back to open while it is still being worked on.
+func updateComplaintStatusHandler(w http.ResponseWriter, r *http.Request) {
+	changeComplaintStatus(w, r, "")
+}
+
+// resolveComplaintHandler marks a complaint as resolved.
+func resolveComplaintHandler(w http.ResponseWriter, r *http.Request) {
+	changeComplaintStatus(w, r, StatusResolved)
+}
+
+// changeComplaintStatus applies a status change to the complaint in the
+// request path. A non-empty status overrides the one in the body.
+func changeComplaintStatus(w http.ResponseWriter, r *http.Request, status ComplaintStatus) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var request struct {
+		Status         ComplaintStatus `json:"status"`
+		ResolutionNote string          `json:"resolutionNote"`
+	}
+
+	// A bearer token is enough to resolve a complaint, so an empty body is
+	// an empty request.
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var changedBy string
+	secretCode := legacySecretCode(r)
+	admin, isAdmin := requestAdmin(r, secretCode)
+	requester, isUser := requestUser(r, secretCode)
+	switch {
+	case isAdmin:
+		changedBy = "admin:" + admin.ID
+	case isUser:
+		changedBy = "user:" + requester.ID
+	default:
+		writeError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if status != "" {
+		request.Status = status
+	}
+	if !request.Status.valid() {
+		writeError(w, "Invalid status", http.StatusBadRequest)
+		return
+	}
+
+	// Check if the complaint exists
+	id := r.PathValue("id")
+	complaintDetails, exists := complaints[id]
+	if !exists {
+		writeError(w, "Complaint not found", http.StatusNotFound)
+		return
+	}
+
+	if !isAdmin {
+		if complaintDetails.UserID != requester.ID {
+			writeError(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		if request.Status != StatusOpen || request.ResolutionNote != "" {
+			writeError(w, "Users may only move their complaint back to open", http.StatusForbidden)
+			return
+		}
+	}
+
+	if !canTransition(complaintDetails.Status, request.Status) {
+		writeError(w, "Cannot change status from "+string(complaintDetails.Status)+" to "+string(request.Status), http.StatusConflict)
+		return
+	}
+
+	original := complaintDetails
+	previous := complaintDetails.Status
+	complaintDetails.setStatus(request.Status, changedBy, time.Now())
+	if request.ResolutionNote != "" {
+		complaintDetails.ResolutionNote = request.ResolutionNote
+	}
+	complaints[id] = complaintDetails
+
+	if isAdmin {
+		action := "status_change"
+		if request.Status == StatusResolved {
+			action = "resolve"
+		}
+		detail := string(previous) + " to " + string(request.Status)
+		if request.ResolutionNote != "" {
+			detail += ": " + request.ResolutionNote
+		}
+		recordAudit(admin.ID, action, id, detail)
+	}
+
+	if err := saveState(); err != nil {
+		complaints[id] = original
+		if isAdmin {
+			dropLastAudit()
+		}
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if status != "" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	json.NewEncoder(w).Encode(complaintDetails)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+var allStatuses = []ComplaintStatus{StatusOpen, StatusInProgress, StatusResolved, StatusRejected}
+
+func TestStatusTransitions(t *testing.T) {
+	resetState(t)
+	_, adminToken := addAdmin(t, "admin-secret")
+	ownerID := registerUser(t, "owner-secret")
+
+	legal := map[[2]ComplaintStatus]bool{
+		{StatusOpen, StatusInProgress}:     true,
+		{StatusOpen, StatusResolved}:       true,
+		{StatusOpen, StatusRejected}:       true,
+		{StatusInProgress, StatusOpen}:     true,
+		{StatusInProgress, StatusResolved}: true,
+		{StatusInProgress, StatusRejected}: true,
+	}
+
+	for _, from := range allStatuses {
+		for _, to := range allStatuses {
+			mu.Lock()
+			id := nextComplaintID()
+			complaints[id] = Complaint{ID: id, Title: "Noise", Severity: 1, UserID: ownerID, Status: from}
+			mu.Unlock()
+
+			want := http.StatusConflict
+			if legal[[2]ComplaintStatus{from, to}] {
+				want = http.StatusOK
+			}
+			if got := canTransition(from, to); got != (want == http.StatusOK) {
+				t.Errorf("canTransition(%s, %s) = %v", from, to, got)
+			}
+
+			w := request(t, http.MethodPatch, "/complaints/"+id+"/status", adminToken, map[string]string{"status": string(to)})
+			if w.Code != want {
+				t.Errorf("%s to %s: status %d, want %d: %s", from, to, w.Code, want, w.Body)
+				continue
+			}
+
+			mu.Lock()
+			got := complaints[id].Status
+			mu.Unlock()
+			if want == http.StatusOK && got != to || want != http.StatusOK && got != from {
+				t.Errorf("%s to %s: complaint is %s afterwards", from, to, got)
+			}
+		}
+	}
+
+	w := request(t, http.MethodPatch, "/complaints/1/status", adminToken, map[string]string{"status": "closed"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unknown status: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestOwnerMayOnlyReopen(t *testing.T) {
+	resetState(t)
+	_, adminToken := addAdmin(t, "admin-secret")
+	ownerID := registerUser(t, "owner-secret")
+	registerUser(t, "other-secret")
+	ownerToken := login(t, "owner-secret")
+	otherToken := login(t, "other-secret")
+	id := submitComplaint(t, ownerToken, "Noise", 2)
+
+	if w := request(t, http.MethodPatch, "/complaints/"+id+"/status", "", map[string]string{"status": "in_progress"}); w.Code != http.StatusUnauthorized {
+		t.Errorf("without a token: status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w := request(t, http.MethodPatch, "/complaints/"+id+"/resolve", ownerToken, nil); w.Code != http.StatusForbidden {
+		t.Errorf("owner resolving: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := request(t, http.MethodPatch, "/complaints/"+id+"/status", adminToken, map[string]string{"status": "in_progress"}); w.Code != http.StatusOK {
+		t.Fatalf("admin starting work: status %d: %s", w.Code, w.Body)
+	}
+	if w := request(t, http.MethodPatch, "/complaints/"+id+"/status", otherToken, map[string]string{"status": "open"}); w.Code != http.StatusForbidden {
+		t.Errorf("another user reopening: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := request(t, http.MethodPatch, "/complaints/"+id+"/status", ownerToken, map[string]string{"status": "open", "resolutionNote": "Never mind"}); w.Code != http.StatusForbidden {
+		t.Errorf("owner reopening with a note: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := request(t, http.MethodPatch, "/complaints/"+id+"/status", ownerToken, map[string]string{"status": "open"}); w.Code != http.StatusOK {
+		t.Fatalf("owner reopening: status %d: %s", w.Code, w.Body)
+	}
+
+	mu.Lock()
+	complaint := complaints[id]
+	mu.Unlock()
+	if complaint.Status != StatusOpen || complaint.StatusChangedBy != "user:"+ownerID {
+		t.Errorf("after reopening: status %s, changed by %q", complaint.Status, complaint.StatusChangedBy)
+	}
+
+	// Reopening an open complaint is not a transition.
+	if w := request(t, http.MethodPatch, "/complaints/"+id+"/status", ownerToken, map[string]string{"status": "open"}); w.Code != http.StatusConflict {
+		t.Errorf("reopening an open complaint: status %d, want %d", w.Code, http.StatusConflict)
+	}
+}
+
+func TestStatusTimestamps(t *testing.T) {
+	resetState(t)
+	adminID, adminToken := addAdmin(t, "admin-secret")
+	registerUser(t, "owner-secret")
+	ownerToken := login(t, "owner-secret")
+
+	start := time.Now()
+	id := submitComplaint(t, ownerToken, "Noise", 2)
+
+	mu.Lock()
+	submitted := complaints[id]
+	mu.Unlock()
+	if submitted.Status != StatusOpen || submitted.CreatedAt.Before(start) || !submitted.UpdatedAt.Equal(submitted.CreatedAt) {
+		t.Errorf("submitted complaint: %+v", submitted)
+	}
+	if submitted.ResolvedAt != nil || submitted.StatusChangedBy != "" {
+		t.Errorf("submitted complaint has resolution details: %+v", submitted)
+	}
+
+	w := request(t, http.MethodPatch, "/complaints/"+id+"/status", adminToken, map[string]string{"status": "in_progress"})
+	var started Complaint
+	decode(t, w, &started)
+	if !started.UpdatedAt.After(submitted.UpdatedAt) || !started.CreatedAt.Equal(submitted.CreatedAt) {
+		t.Errorf("after starting work: created %v, updated %v", started.CreatedAt, started.UpdatedAt)
+	}
+	if started.ResolvedAt != nil || started.StatusChangedBy != "admin:"+adminID {
+		t.Errorf("after starting work: resolved at %v, changed by %q", started.ResolvedAt, started.StatusChangedBy)
+	}
+
+	w = request(t, http.MethodPatch, "/complaints/"+id+"/status", adminToken, map[string]string{"status": "resolved", "resolutionNote": "Fixed"})
+	var resolved Complaint
+	decode(t, w, &resolved)
+	if resolved.ResolvedAt == nil || !resolved.ResolvedAt.Equal(resolved.UpdatedAt) || !resolved.UpdatedAt.After(started.UpdatedAt) {
+		t.Errorf("after resolving: updated %v, resolved at %v", resolved.UpdatedAt, resolved.ResolvedAt)
+	}
+	if resolved.ResolutionNote != "Fixed" {
+		t.Errorf("resolution note %q, want %q", resolved.ResolutionNote, "Fixed")
+	}
+}
+
+func TestResolvedFieldIsDerivedFromStatus(t *testing.T) {
+	for _, status := range allStatuses {
+		data, err := json.Marshal(Complaint{ID: "1", Status: status})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := fields["resolved"], status == StatusResolved; got != want {
+			t.Errorf("%s: resolved = %v, want %v", status, got, want)
+		}
+		if fields["status"] != string(status) {
+			t.Errorf("%s: status = %v", status, fields["status"])
+		}
+	}
+
+	// Complaints saved before statuses existed only have the flag.
+	for data, want := range map[string]ComplaintStatus{
+		`{"id":"1","resolved":true}`:  StatusResolved,
+		`{"id":"1","resolved":false}`: StatusOpen,
+		`{"id":"1"}`:                  StatusOpen,
+	} {
+		var c Complaint
+		if err := json.Unmarshal([]byte(data), &c); err != nil {
+			t.Fatal(err)
+		}
+		if c.Status != want {
+			t.Errorf("%s: status %s, want %s", data, c.Status, want)
+		}
+	}
+}
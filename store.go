@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Store is the on-disk snapshot of the server state
+type Store struct {
+	Users           map[string]User      `json:"users"`
+	Complaints      map[string]Complaint `json:"complaints"`
+	AuditLog        []AuditEntry         `json:"auditLog"`
+	LastUserID      int64                `json:"lastUserId"`
+	LastComplaintID int64                `json:"lastComplaintId"`
+}
+
+// Load reads the store from path. A missing file leaves the store empty.
+func (s *Store) Load(path string) error {
+	*s = Store{}
+	s.Users = make(map[string]User)
+	s.Complaints = make(map[string]Complaint)
+	s.AuditLog = []AuditEntry{}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return err
+	}
+
+	if s.Users == nil {
+		s.Users = make(map[string]User)
+	}
+	if s.Complaints == nil {
+		s.Complaints = make(map[string]Complaint)
+	}
+	if s.AuditLog == nil {
+		s.AuditLog = []AuditEntry{}
+	}
+
+	// Secret codes are never serialized on the records themselves; they
+	// are the keys of the users map.
+	for secretCode, user := range s.Users {
+		user.SecretCode = secretCode
+		s.Users[secretCode] = user
+	}
+
+	// Never hand out an ID that is already in use, even if the counters
+	// are missing or behind, as in a file edited by hand.
+	for _, user := range s.Users {
+		s.LastUserID = maxID(s.LastUserID, user.ID)
+	}
+	for id := range s.Complaints {
+		s.LastComplaintID = maxID(s.LastComplaintID, id)
+	}
+	return nil
+}
+
+// maxID returns the larger of current and the numeric value of id.
+func maxID(current int64, id string) int64 {
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || n < current {
+		return current
+	}
+	return n
+}
+
+// Save writes the store to path. The data is written and synced to a
+// temporary file in the same directory and renamed into place so a crash
+// never leaves a partially written file behind.
+func (s *Store) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// saveState persists the current users, complaints and audit log.
+// Callers must hold mu.
+func saveState() error {
+	store := Store{
+		Users:           users,
+		Complaints:      complaints,
+		AuditLog:        auditLog,
+		LastUserID:      lastUserID.Load(),
+		LastComplaintID: lastComplaintID.Load(),
+	}
+	return store.Save(dataFile)
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStoreRoundTrip(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	resolved := created.Add(time.Hour)
+
+	want := Store{
+		Users: map[string]User{
+			"secret-one": {ID: "1", SecretCode: "secret-one", Name: "One", Email: "one@example.com", Role: RoleUser},
+			"secret-two": {ID: "2", SecretCode: "secret-two", Name: "Two", Email: "two@example.com", Role: RoleAdmin},
+		},
+		Complaints: map[string]Complaint{
+			"1": {ID: "1", Title: "Noise", Summary: "Loud", Severity: 2, UserID: "1",
+				Status: StatusResolved, StatusChangedBy: "admin:2", CreatedAt: created, UpdatedAt: resolved,
+				ResolutionNote: "Fixed", ResolvedAt: &resolved},
+		},
+		AuditLog: []AuditEntry{
+			{Timestamp: resolved, AdminID: "2", Action: "resolve", ComplaintID: "1", Detail: "open to resolved: Fixed"},
+		},
+		LastUserID:      2,
+		LastComplaintID: 1,
+	}
+
+	path := filepath.Join(t.TempDir(), "data.json")
+	if err := want.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	var got Store
+	if err := got.Load(path); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Load after Save = %+v, want %+v", got, want)
+	}
+}
+
+func TestStoreLoadMissingFile(t *testing.T) {
+	var s Store
+	if err := s.Load(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(s.Users) != 0 || len(s.Complaints) != 0 || s.LastUserID != 0 || s.LastComplaintID != 0 {
+		t.Errorf("Load of a missing file = %+v, want an empty store", s)
+	}
+	if s.Users == nil || s.Complaints == nil {
+		t.Error("Load of a missing file left nil maps")
+	}
+}
+
+func TestIDsContinueAfterReload(t *testing.T) {
+	resetState(t)
+
+	// Complaint 3 was deleted before the save, so only the counter still
+	// remembers it.
+	saved := Store{
+		Users: map[string]User{
+			"secret-one": {ID: "1", SecretCode: "secret-one", Name: "One", Email: "one@example.com"},
+		},
+		Complaints: map[string]Complaint{
+			"2": {ID: "2", Title: "Noise", Severity: 1, UserID: "1"},
+		},
+		LastUserID:      1,
+		LastComplaintID: 3,
+	}
+	if err := saved.Save(dataFile); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	// Load the file the way main does.
+	var s Store
+	if err := s.Load(dataFile); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	lastUserID.Store(s.LastUserID)
+	lastComplaintID.Store(s.LastComplaintID)
+
+	if id := nextUserID(); id != "2" {
+		t.Errorf("nextUserID() = %q, want %q", id, "2")
+	}
+	if id := nextComplaintID(); id != "4" {
+		t.Errorf("nextComplaintID() = %q, want %q", id, "4")
+	}
+}
+
+func TestStoreLoadDerivesMissingCounters(t *testing.T) {
+	// A file edited by hand may only have the IDs on the records
+	// themselves.
+	saved := Store{
+		Users: map[string]User{
+			"secret-one": {ID: "7", SecretCode: "secret-one", Name: "One"},
+		},
+		Complaints: map[string]Complaint{
+			"12": {ID: "12", Title: "Noise", Severity: 1, UserID: "1"},
+		},
+	}
+	path := filepath.Join(t.TempDir(), "data.json")
+	if err := saved.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	var s Store
+	if err := s.Load(path); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if s.LastUserID != 7 || s.LastComplaintID != 12 {
+		t.Errorf("counters = %d, %d, want 7, 12", s.LastUserID, s.LastComplaintID)
+	}
+}
+
+// snapshotState returns the users, complaints and audit log as JSON.
+func snapshotState(t *testing.T) string {
+	t.Helper()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	data, err := json.Marshal(Store{Users: users, Complaints: complaints, AuditLog: auditLog})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestFailedSaveChangesNothing(t *testing.T) {
+	resetState(t)
+	adminID, adminToken := addAdmin(t, "admin-secret")
+	userID := registerUser(t, "user-secret")
+	token := login(t, "user-secret")
+	id := submitComplaint(t, token, "Noise", 2)
+	if w := request(t, http.MethodPatch, "/users/me/preferences", token, map[string]string{"theme": "light"}); w.Code != http.StatusOK {
+		t.Fatalf("setting preferences: status %d: %s", w.Code, w.Body)
+	}
+
+	// A directory that does not exist cannot be written to, even by root.
+	writable := dataFile
+	dataFile = filepath.Join(t.TempDir(), "missing", "data.json")
+
+	tests := []struct {
+		name, method, target, token string
+		body                        interface{}
+	}{
+		{"register", http.MethodPost, "/users", "", map[string]string{"secretCode": "other-secret", "name": "Other", "email": "other@example.com"}},
+		{"submit", http.MethodPost, "/complaints", token, map[string]interface{}{"title": "Leak", "severity": 1}},
+		{"preferences", http.MethodPatch, "/users/me/preferences", token, map[string]string{"theme": "dark", "locale": "en-GB"}},
+		{"update", http.MethodPatch, "/complaints/" + id, token, map[string]interface{}{"title": "Changed", "severity": 5}},
+		{"withdraw", http.MethodDelete, "/complaints/" + id, token, nil},
+		{"admin delete", http.MethodDelete, "/complaints/" + id, adminToken, nil},
+		{"resolve", http.MethodPatch, "/complaints/" + id + "/resolve", adminToken, nil},
+		{"change status", http.MethodPatch, "/complaints/" + id + "/status", adminToken, map[string]string{"status": "in_progress"}},
+		{"register admin", http.MethodPost, "/registerAdmin", adminToken, map[string]string{"secretCode": "second-admin", "name": "Second", "email": "second@example.com"}},
+		{"change role", http.MethodPatch, "/admin/users/" + userID + "/role", adminToken, map[string]string{"role": RoleAdmin}},
+		{"import", http.MethodPost, "/admin/complaints/import", adminToken, map[string]interface{}{"userSecretCode": "user-secret", "complaints": []interface{}{map[string]interface{}{"title": "Old", "severity": 1}}}},
+		{"reset rate limit", http.MethodDelete, "/admin/ratelimits/" + userID, adminToken, nil},
+	}
+
+	for _, tt := range tests {
+		before := snapshotState(t)
+		w := request(t, tt.method, tt.target, tt.token, tt.body)
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, w.Code, http.StatusInternalServerError, w.Body)
+		}
+		if after := snapshotState(t); after != before {
+			t.Errorf("%s: state changed by a request that failed to save:\nbefore %s\nafter  %s", tt.name, before, after)
+		}
+	}
+
+	rateLimitMu.Lock()
+	_, kept := userComplaintRateLimit[userID]
+	rateLimitMu.Unlock()
+	if !kept {
+		t.Error("a rate limit reset that failed to save was applied")
+	}
+
+	// Once the data file can be written again, only the retried action is
+	// audited.
+	dataFile = writable
+	if w := request(t, http.MethodPatch, "/complaints/"+id+"/resolve", adminToken, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("resolving again: status %d: %s", w.Code, w.Body)
+	}
+
+	var s Store
+	if err := s.Load(dataFile); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(s.AuditLog) != 1 || s.AuditLog[0].AdminID != adminID || s.AuditLog[0].Action != "resolve" {
+		t.Errorf("saved audit log = %+v, want only the resolve", s.AuditLog)
+	}
+}
+
+func TestSaveStateIsReadByAFreshStore(t *testing.T) {
+	resetState(t)
+
+	// The times carry no monotonic reading, which does not survive JSON.
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	mu.Lock()
+	complaint := Complaint{ID: nextComplaintID(), Title: "Noise", Severity: 3, UserID: "1",
+		Status: StatusOpen, CreatedAt: created, UpdatedAt: created}
+	user := User{ID: nextUserID(), SecretCode: "secret-one", Name: "One", Email: "one@example.com"}
+	putUser(user)
+	complaints[complaint.ID] = complaint
+	err := saveState()
+	mu.Unlock()
+	if err != nil {
+		t.Fatalf("saveState: %v", err)
+	}
+
+	var s Store
+	if err := s.Load(dataFile); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := s.Users["secret-one"]; !reflect.DeepEqual(got, user) {
+		t.Errorf("loaded user = %+v, want %+v", got, user)
+	}
+	if got := s.Complaints[complaint.ID]; !reflect.DeepEqual(got, complaint) {
+		t.Errorf("loaded complaint = %+v, want %+v", got, complaint)
+	}
+	if s.LastUserID != 1 || s.LastComplaintID != 1 {
+		t.Errorf("counters = %d, %d, want 1, 1", s.LastUserID, s.LastComplaintID)
+	}
+}
+
+func TestStoreLoadDerivesStatusFromResolved(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.json")
+	data := `{
+		"complaints": {"1": {"id": "1", "resolved": false}, "2": {"id": "2", "resolved": true}}
+	}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var s Store
+	if err := s.Load(path); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := s.Complaints["1"].Status; got != StatusOpen {
+		t.Errorf("unresolved complaint has status %q, want %q", got, StatusOpen)
+	}
+	if got := s.Complaints["2"].Status; got != StatusResolved {
+		t.Errorf("resolved complaint has status %q, want %q", got, StatusResolved)
+	}
+}
+
+func TestSaveReplacesFileWithoutLeavingTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.json")
+
+	for _, name := range []string{"First", "Second"} {
+		s := Store{Users: map[string]User{"secret-one": {ID: "1", SecretCode: "secret-one", Name: name}}}
+		if err := s.Save(path); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+
+	var s Store
+	if err := s.Load(path); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := s.Users["secret-one"].Name; got != "Second" {
+		t.Errorf("name after second Save = %q, want %q", got, "Second")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("directory holds %v, want only data.json", names)
+	}
+}
+
+func TestFailedBootstrapAddsNoAdmin(t *testing.T) {
+	resetState(t)
+	registerUser(t, "user-secret")
+	dataFile = filepath.Join(t.TempDir(), "missing", "data.json")
+
+	for _, secretCode := range []string{"admin-secret", "user-secret"} {
+		before := snapshotState(t)
+		if err := bootstrapAdmin(secretCode); err == nil {
+			t.Errorf("bootstrapAdmin(%s) succeeded without saving", secretCode)
+		}
+		if after := snapshotState(t); after != before {
+			t.Errorf("bootstrapAdmin(%s) changed the state: %s", secretCode, after)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+)
+
+const (
+	minSecretCodeLength = 8
+	maxNameLength       = 100
+	maxTitleLength      = 200
+	maxSummaryLength    = 2000
+	minSeverity         = 1
+	maxSeverity         = 5
+)
+
+// validationErrors maps each invalid field to the reason it was rejected.
+type validationErrors map[string]string
+
+// decodeStrict decodes the JSON request body into v, rejecting fields v
+// does not declare.
+func decodeStrict(r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// validate checks v against the rules for its type. Only users and
+// complaints are validated; any other value passes.
+func validate(v interface{}) validationErrors {
+	switch v := v.(type) {
+	case User:
+		return validateAccount(v.Name, v.Email, v.SecretCode)
+	case Complaint:
+		return validateComplaint(v)
+	}
+	return validationErrors{}
+}
+
+func validateAccount(name, email, secretCode string) validationErrors {
+	errs := validationErrors{}
+
+	if name == "" {
+		errs["name"] = "is required"
+	} else if len(name) > maxNameLength {
+		errs["name"] = fmt.Sprintf("must be at most %d characters", maxNameLength)
+	}
+
+	if email == "" {
+		errs["email"] = "is required"
+	} else if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		errs["email"] = "invalid format"
+	}
+
+	if len(secretCode) < minSecretCodeLength {
+		errs["secretCode"] = fmt.Sprintf("must be at least %d characters", minSecretCodeLength)
+	}
+
+	return errs
+}
+
+func validateComplaint(complaint Complaint) validationErrors {
+	errs := validationErrors{}
+
+	if complaint.Title == "" {
+		errs["title"] = "is required"
+	} else if len(complaint.Title) > maxTitleLength {
+		errs["title"] = fmt.Sprintf("must be at most %d characters", maxTitleLength)
+	}
+
+	if len(complaint.Summary) > maxSummaryLength {
+		errs["summary"] = fmt.Sprintf("must be at most %d characters", maxSummaryLength)
+	}
+
+	if complaint.Severity < minSeverity || complaint.Severity > maxSeverity {
+		errs["severity"] = fmt.Sprintf("must be between %d and %d", minSeverity, maxSeverity)
+	}
+
+	return errs
+}
+
+// writeValidationErrors reports every failing field at once.
+func writeValidationErrors(w http.ResponseWriter, errs validationErrors) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(struct {
+		Errors validationErrors `json:"errors"`
+	}{errs})
+}
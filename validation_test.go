@@ -0,0 +1,227 @@
+package main
+
+import (
+	"maps"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateUser(t *testing.T) {
+	valid := User{SecretCode: "secret-code", Name: "Ada", Email: "ada@example.com"}
+
+	tests := []struct {
+		name   string
+		change func(*User)
+		want   validationErrors
+	}{
+		{"valid", func(*User) {}, validationErrors{}},
+		{"missing name", func(u *User) { u.Name = "" }, validationErrors{"name": "is required"}},
+		{"longest name", func(u *User) { u.Name = strings.Repeat("n", maxNameLength) }, validationErrors{}},
+		{"name too long", func(u *User) { u.Name = strings.Repeat("n", maxNameLength+1) }, validationErrors{"name": "must be at most 100 characters"}},
+		{"missing email", func(u *User) { u.Email = "" }, validationErrors{"email": "is required"}},
+		{"email without @", func(u *User) { u.Email = "ada.example.com" }, validationErrors{"email": "invalid format"}},
+		{"email with a display name", func(u *User) { u.Email = "Ada <ada@example.com>" }, validationErrors{"email": "invalid format"}},
+		{"empty secret code", func(u *User) { u.SecretCode = "" }, validationErrors{"secretCode": "must be at least 8 characters"}},
+		{"short secret code", func(u *User) { u.SecretCode = "1234567" }, validationErrors{"secretCode": "must be at least 8 characters"}},
+		{"shortest secret code", func(u *User) { u.SecretCode = "12345678" }, validationErrors{}},
+		{"everything wrong", func(u *User) { *u = User{} }, validationErrors{
+			"name":       "is required",
+			"email":      "is required",
+			"secretCode": "must be at least 8 characters",
+		}},
+	}
+
+	for _, tt := range tests {
+		user := valid
+		tt.change(&user)
+		if got := validate(user); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: validate = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateComplaint(t *testing.T) {
+	valid := Complaint{Title: "Noise", Summary: "Loud music", Severity: 3}
+
+	tests := []struct {
+		name   string
+		change func(*Complaint)
+		want   validationErrors
+	}{
+		{"valid", func(*Complaint) {}, validationErrors{}},
+		{"missing title", func(c *Complaint) { c.Title = "" }, validationErrors{"title": "is required"}},
+		{"longest title", func(c *Complaint) { c.Title = strings.Repeat("t", maxTitleLength) }, validationErrors{}},
+		{"title too long", func(c *Complaint) { c.Title = strings.Repeat("t", maxTitleLength+1) }, validationErrors{"title": "must be at most 200 characters"}},
+		{"empty summary", func(c *Complaint) { c.Summary = "" }, validationErrors{}},
+		{"longest summary", func(c *Complaint) { c.Summary = strings.Repeat("s", maxSummaryLength) }, validationErrors{}},
+		{"summary too long", func(c *Complaint) { c.Summary = strings.Repeat("s", maxSummaryLength+1) }, validationErrors{"summary": "must be at most 2000 characters"}},
+		{"severity 0", func(c *Complaint) { c.Severity = 0 }, validationErrors{"severity": "must be between 1 and 5"}},
+		{"severity 1", func(c *Complaint) { c.Severity = 1 }, validationErrors{}},
+		{"severity 5", func(c *Complaint) { c.Severity = 5 }, validationErrors{}},
+		{"severity 6", func(c *Complaint) { c.Severity = 6 }, validationErrors{"severity": "must be between 1 and 5"}},
+		{"negative severity", func(c *Complaint) { c.Severity = -1 }, validationErrors{"severity": "must be between 1 and 5"}},
+	}
+
+	for _, tt := range tests {
+		complaint := valid
+		tt.change(&complaint)
+		if got := validate(complaint); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: validate = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRegisterRejectsEachInvalidField(t *testing.T) {
+	resetState(t)
+	config.AuthRequestBurst = 1000
+
+	valid := map[string]string{"secretCode": "user-secret", "name": "Ada", "email": "ada@example.com"}
+
+	tests := []struct {
+		field, value, want string
+	}{
+		{"secretCode", "1234567", "must be at least 8 characters"},
+		{"name", "", "is required"},
+		{"name", strings.Repeat("n", maxNameLength+1), "must be at most 100 characters"},
+		{"email", "", "is required"},
+		{"email", "ada@", "invalid format"},
+		{"email", "ada at example.com", "invalid format"},
+	}
+
+	for _, tt := range tests {
+		body := maps.Clone(valid)
+		body[tt.field] = tt.value
+
+		w := request(t, http.MethodPost, "/users", "", body)
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s %q: status %d, want %d", tt.field, tt.value, w.Code, http.StatusUnprocessableEntity)
+			continue
+		}
+		var got map[string]validationErrors
+		decode(t, w, &got)
+		if want := (validationErrors{tt.field: tt.want}); !reflect.DeepEqual(got["errors"], want) {
+			t.Errorf("%s %q: errors %v, want %v", tt.field, tt.value, got["errors"], want)
+		}
+	}
+
+	mu.Lock()
+	n := len(users)
+	mu.Unlock()
+	if n != 0 {
+		t.Fatalf("%d invalid users were stored", n)
+	}
+
+	if w := request(t, http.MethodPost, "/users", "", valid); w.Code != http.StatusOK {
+		t.Errorf("valid registration: status %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestSubmitRejectsEachInvalidField(t *testing.T) {
+	resetState(t)
+	registerUser(t, "user-secret")
+	token := login(t, "user-secret")
+
+	tests := []struct {
+		field string
+		value interface{}
+		want  string
+	}{
+		{"title", "", "is required"},
+		{"title", strings.Repeat("t", maxTitleLength+1), "must be at most 200 characters"},
+		{"summary", strings.Repeat("s", maxSummaryLength+1), "must be at most 2000 characters"},
+		{"severity", 0, "must be between 1 and 5"},
+		{"severity", 6, "must be between 1 and 5"},
+	}
+
+	for _, tt := range tests {
+		body := map[string]interface{}{"title": "Noise", "summary": "Loud music", "severity": 3}
+		body[tt.field] = tt.value
+
+		w := request(t, http.MethodPost, "/complaints", token, body)
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s %v: status %d, want %d", tt.field, tt.value, w.Code, http.StatusUnprocessableEntity)
+			continue
+		}
+		var got map[string]validationErrors
+		decode(t, w, &got)
+		if want := (validationErrors{tt.field: tt.want}); !reflect.DeepEqual(got["errors"], want) {
+			t.Errorf("%s %v: errors %v, want %v", tt.field, tt.value, got["errors"], want)
+		}
+	}
+
+	// The limits themselves are allowed.
+	for _, severity := range []int{minSeverity, maxSeverity} {
+		body := map[string]interface{}{"title": strings.Repeat("t", maxTitleLength), "summary": "", "severity": severity}
+		if w := request(t, http.MethodPost, "/complaints", token, body); w.Code != http.StatusCreated {
+			t.Errorf("valid complaint with severity %d: status %d: %s", severity, w.Code, w.Body)
+		}
+	}
+}
+
+func TestValidationErrorsAreReportedTogether(t *testing.T) {
+	resetState(t)
+	registerUser(t, "user-secret")
+	token := login(t, "user-secret")
+
+	w := request(t, http.MethodPost, "/complaints", token, map[string]interface{}{
+		"title":    "",
+		"summary":  strings.Repeat("s", maxSummaryLength+1),
+		"severity": 0,
+	})
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status %d, want %d: %s", w.Code, http.StatusUnprocessableEntity, w.Body)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type %q, want application/json", got)
+	}
+
+	var body struct {
+		Errors map[string]string `json:"errors"`
+	}
+	decode(t, w, &body)
+	want := map[string]string{
+		"title":    "is required",
+		"summary":  "must be at most 2000 characters",
+		"severity": "must be between 1 and 5",
+	}
+	if !reflect.DeepEqual(body.Errors, want) {
+		t.Errorf("body = %+v, want every failing field in errors", body)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(complaints) != 0 {
+		t.Errorf("invalid complaint was stored: %v", complaints)
+	}
+}
+
+func TestUnknownFieldsAreRejected(t *testing.T) {
+	resetState(t)
+	registerUser(t, "user-secret")
+	token := login(t, "user-secret")
+	id := submitComplaint(t, token, "Noise", 2)
+
+	tests := []struct {
+		method, target, token string
+		body                  map[string]interface{}
+	}{
+		{http.MethodPost, "/users", "", map[string]interface{}{"secretCode": "other-secret", "name": "Other", "email": "other@example.com", "isAdmin": true}},
+		{http.MethodPost, "/complaints", token, map[string]interface{}{"title": "Leak", "severity": 1, "resolved": true}},
+		{http.MethodPatch, "/complaints/" + id, token, map[string]interface{}{"severity": 3, "resolved": true}},
+		{http.MethodPatch, "/updateComplaint", "", map[string]interface{}{"id": id, "secretCode": "user-secret", "severity": 3, "resolved": true}},
+	}
+
+	for _, tt := range tests {
+		if w := request(t, tt.method, tt.target, tt.token, tt.body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: status %d, want %d: %s", tt.method, tt.target, w.Code, http.StatusBadRequest, w.Body)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(users) != 1 || len(complaints) != 1 || complaints[id].Severity != 2 || complaints[id].Status != StatusOpen {
+		t.Errorf("a rejected request changed the state: users %v, complaints %v", users, complaints)
+	}
+}